@@ -0,0 +1,203 @@
+// Package admin is the server's operator API: it lists the sessions in
+// progress, terminates them on request, and changes log levels at runtime.
+//
+//	GET    /admin/sessions       the sessions, as a JSON array
+//	DELETE /admin/sessions/{id}  terminates a session
+//	GET    /admin/log            the log levels, by subsystem ("" is the default)
+//	PUT    /admin/log            sets log levels from a body like "warn,bot=debug"
+//
+// With a token, every request must carry "Authorization: Bearer <token>".
+// Without one, only requests from the loopback interface are allowed.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/perlmonger42/greedy-bot/logging"
+	"github.com/perlmonger42/greedy-bot/pao"
+)
+
+// Session is what the admin API needs of a session.
+type Session interface {
+	Status() pao.Status
+	Terminate()
+}
+
+// Registry keeps track of the sessions in progress.
+type Registry struct {
+	mu       sync.Mutex
+	sessions map[int64]entry
+}
+
+type entry struct {
+	remote  string
+	session Session
+}
+
+func NewRegistry() *Registry {
+	return &Registry{sessions: map[int64]entry{}}
+}
+
+// Add registers a session under its ID, with the address it connected from.
+func (r *Registry) Add(id int64, remote string, s Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[id] = entry{remote: remote, session: s}
+}
+
+// Remove forgets a session, once it has ended.
+func (r *Registry) Remove(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, id)
+}
+
+// Get finds a session by ID.
+func (r *Registry) Get(id int64) (Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.sessions[id]
+	return e.session, ok
+}
+
+// Len counts the sessions in progress.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessions)
+}
+
+// Info describes a session in the API.
+type Info struct {
+	ID     int64  `json:"id"`
+	Remote string `json:"remote"`
+	pao.Status
+}
+
+// snapshot copies the sessions, so that they can be used without the lock.
+func (r *Registry) snapshot() map[int64]entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := map[int64]entry{}
+	for id, e := range r.sessions {
+		entries[id] = e
+	}
+	return entries
+}
+
+// Each calls f for every session in progress.
+func (r *Registry) Each(f func(id int64, s Session)) {
+	for id, e := range r.snapshot() {
+		f(id, e.session)
+	}
+}
+
+// List describes every session, in order of ID.
+func (r *Registry) List() []Info {
+	infos := []Info{}
+	for id, e := range r.snapshot() {
+		infos = append(infos, Info{ID: id, Remote: e.remote, Status: e.session.Status()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Handler serves the admin API for the sessions in reg, changing the
+// levels of log, which should be the root of the server's loggers.
+func Handler(reg *Registry, log *logging.Logger, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/sessions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, reg.List())
+	})
+	mux.HandleFunc("/admin/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/admin/sessions/"), 10, 64)
+		if err != nil {
+			http.Error(w, "bad session ID", http.StatusBadRequest)
+			return
+		}
+		s, ok := reg.Get(id)
+		if !ok {
+			http.Error(w, "no such session", http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, Info{ID: id, Status: s.Status()})
+		case http.MethodDelete:
+			log.Sub("admin").Info("terminating session", "id", id)
+			s.Terminate()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "use GET or DELETE", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/admin/log", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1024))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := log.Configure(string(body)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Sub("admin").Info("log levels changed", "levels", strings.TrimSpace(string(body)))
+		default:
+			http.Error(w, "use GET or PUT", http.StatusMethodNotAllowed)
+			return
+		}
+		levels := map[string]string{}
+		for sys, level := range log.Levels() {
+			levels[sys] = level.String()
+		}
+		writeJSON(w, levels)
+	})
+	return authorize(mux, token)
+}
+
+// authorize lets through requests with the token, or without one, from
+// the loopback interface.
+func authorize(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else if !fromLoopback(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
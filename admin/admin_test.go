@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/logging"
+	"github.com/perlmonger42/greedy-bot/pao"
+)
+
+type fakeSession struct {
+	status     pao.Status
+	terminated bool
+}
+
+func (s *fakeSession) Status() pao.Status { return s.status }
+func (s *fakeSession) Terminate()         { s.terminated = true }
+
+func request(h http.Handler, method, path, remote, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.RemoteAddr = remote
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestSessions(t *testing.T) {
+	reg := NewRegistry()
+	red := &fakeSession{status: pao.Status{Bot: "Greedy", Color: "red", Moves: 7}}
+	reg.Add(2, "10.0.0.2:5000", red)
+	reg.Add(1, "10.0.0.1:5000", &fakeSession{status: pao.Status{Bot: "AlphaBeta"}})
+	h := Handler(reg, logging.New(&bytes.Buffer{}), "")
+
+	w := request(h, "GET", "/admin/sessions", "127.0.0.1:1234", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /admin/sessions: %d %s", w.Code, w.Body)
+	}
+	var infos []Info
+	if err := json.Unmarshal(w.Body.Bytes(), &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].ID != 1 || infos[1].ID != 2 {
+		t.Fatalf("sessions = %+v, want IDs 1 and 2", infos)
+	}
+	if got := infos[1]; got.Bot != "Greedy" || got.Color != "red" || got.Moves != 7 || got.Remote != "10.0.0.2:5000" {
+		t.Errorf("session 2 = %+v", got)
+	}
+
+	if w := request(h, "DELETE", "/admin/sessions/3", "127.0.0.1:1234", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE of a missing session: %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := request(h, "DELETE", "/admin/sessions/2", "127.0.0.1:1234", "", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE /admin/sessions/2: %d %s", w.Code, w.Body)
+	}
+	if !red.terminated {
+		t.Errorf("session 2 was not terminated")
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	log := logging.New(&bytes.Buffer{})
+	h := Handler(NewRegistry(), log, "")
+	w := request(h, "PUT", "/admin/log", "[::1]:1234", "", "warn,bot=debug")
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT /admin/log: %d %s", w.Code, w.Body)
+	}
+	var levels map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &levels); err != nil {
+		t.Fatal(err)
+	}
+	if levels[""] != "warn" || levels["bot"] != "debug" {
+		t.Errorf("levels = %v, want warn with bot=debug", levels)
+	}
+	if !log.Sub("bot").Enabled(logging.Debug) || log.Sub("pao").Enabled(logging.Info) {
+		t.Errorf("levels were not applied")
+	}
+	if w := request(h, "PUT", "/admin/log", "127.0.0.1:1234", "", "loud"); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT of a bad level: %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	open := Handler(NewRegistry(), nil, "")
+	if w := request(open, "GET", "/admin/sessions", "192.0.2.1:1234", "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("remote request without a token: %d, want %d", w.Code, http.StatusForbidden)
+	}
+	locked := Handler(NewRegistry(), nil, "sesame")
+	for _, test := range []struct {
+		token string
+		want  int
+	}{
+		{"", http.StatusUnauthorized},
+		{"guess", http.StatusUnauthorized},
+		{"sesame", http.StatusOK},
+	} {
+		if w := request(locked, "GET", "/admin/sessions", "192.0.2.1:1234", test.token, ""); w.Code != test.want {
+			t.Errorf("token %q: %d, want %d", test.token, w.Code, test.want)
+		}
+	}
+}
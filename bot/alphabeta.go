@@ -0,0 +1,155 @@
+// Implement a Pao bot that looks several moves ahead with an alpha-beta
+// search, deepening iteratively until its time budget runs out.
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/logging"
+	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/search"
+	"github.com/perlmonger42/greedy-bot/tablebase"
+)
+
+type AlphaBetaBot struct {
+	Budget     time.Duration   // time allowed for choosing each move
+	Tablebases *tablebase.Set  // consulted once every piece is face up, if set
+	Log        *logging.Logger // for the bot's reasoning, at debug level
+	searcher   *search.Parallel
+	last       search.Result
+	fromTables bool                     // whether the last move came from the tablebases
+	pondered   map[uint64]search.Result // our replies to the opponent's likely moves
+}
+
+// NewAlphaBetaBot builds a bot that searches with the given number of
+// goroutines. One worker keeps its play reproducible; more workers make
+// better use of a multi-core host.
+func NewAlphaBetaBot(budget time.Duration, workers int) *AlphaBetaBot {
+	return &AlphaBetaBot{
+		Budget:   budget,
+		searcher: search.NewParallel(search.Material{}, workers),
+	}
+}
+
+func (bot *AlphaBetaBot) Name() string {
+	return "AlphaBeta"
+}
+
+// Searcher exposes the underlying search, so that its evaluator, flip
+// policy, depth limit, worker count and seed can be adjusted.
+func (bot *AlphaBetaBot) Searcher() *search.Parallel {
+	return bot.searcher
+}
+
+// Reseed sets the seed of the helpers' shuffling. A single worker doesn't
+// shuffle, and with a depth limit and no time budget it replays a game
+// exactly regardless of the seed.
+func (bot *AlphaBetaBot) Reseed(seed int64) {
+	bot.searcher.Seed = seed
+}
+
+func (bot *AlphaBetaBot) ChooseMove(state *game.State) move.T {
+	bot.Log.Debug("choosing a move", "board", &state.Board, "dead", len(state.Dead))
+	if bot.Tablebases != nil {
+		if m, r, ok := bot.Tablebases.BestMove(state); ok {
+			bot.Log.Debug("tablebase move", "move", m.String(), "result", r.WDL, "distance", r.Distance)
+			bot.last = search.Result{Move: m, Score: tableScore(r)}
+			bot.pondered, bot.fromTables = nil, true
+			return m
+		}
+	}
+	if result, ok := bot.pondered[search.Hash(state)]; ok {
+		bot.Log.Debug("reusing the reply found while pondering")
+		bot.last = result
+	} else {
+		bot.last = bot.searcher.Search(state, bot.Budget)
+	}
+	bot.pondered, bot.fromTables = nil, false
+	bot.Log.Debug("best move", "move", bot.last.Move.String(), "score", bot.last.Score,
+		"depth", bot.last.Depth, "nodes", bot.last.Nodes, "elapsed", bot.last.Elapsed)
+	return bot.last.Move
+}
+
+// LastResult reports the search that chose the most recent move, including
+// the number of nodes searched and the depth reached.
+func (bot *AlphaBetaBot) LastResult() search.Result {
+	return bot.last
+}
+
+// Ponder uses the opponent's thinking time: state is the position after our
+// move, and for each of the opponent's likely replies (captures first), we
+// search for our answer and remember it in case that reply is played.
+// Flips are skipped, since we can't know what they will turn up. Ponder
+// returns when every reply has been searched or ctx is cancelled.
+func (bot *AlphaBetaBot) Ponder(ctx context.Context, state *game.State) {
+	bot.pondered = map[uint64]search.Result{}
+	if state.Us == nil {
+		return
+	}
+	replies := move.LegalMoves(state.Us, state.Them, state.Board)
+	sort.SliceStable(replies, func(i, j int) bool {
+		return replies[i].Action() == move.Take && replies[j].Action() != move.Take
+	})
+	for _, reply := range replies {
+		if reply.Action() == move.Flip {
+			continue
+		}
+		next := reply.Apply(state)
+		result := bot.searcher.SearchContext(ctx, &next, bot.Budget)
+		if ctx.Err() != nil {
+			return
+		}
+		bot.pondered[search.Hash(&next)] = result
+	}
+}
+
+// OpponentReplied keeps only our answer to reply, the move the opponent
+// played in state, the position we last pondered; the rest of what Ponder
+// found no longer applies.
+func (bot *AlphaBetaBot) OpponentReplied(state *game.State, reply move.T) {
+	next := reply.Apply(state)
+	key := search.Hash(&next)
+	result, ok := bot.pondered[key]
+	bot.pondered = nil
+	if ok {
+		bot.Log.Debug("pondered the opponent's reply", "move", reply.String())
+		bot.pondered = map[uint64]search.Result{key: result}
+	}
+}
+
+// Explain says why the bot chose its last move.
+func (bot *AlphaBetaBot) Explain() string {
+	if bot.fromTables {
+		return "the endgame tablebases say it's best"
+	}
+	return fmt.Sprintf("searching %d moves deep (%d positions) scored it %+d", bot.last.Depth,
+		bot.last.Nodes, bot.last.Score)
+}
+
+// LastScore is the score of the most recent move: the search's, or the
+// tablebases' value of the position, as tableScore scores it.
+func (bot *AlphaBetaBot) LastScore() int {
+	return bot.last.Score
+}
+
+// tableScore puts a tablebase result on the search's scale: a win or loss
+// r.Distance plies off scores like one the search found that far away,
+// though no further off than MaxPly, so that it still reads as a win or
+// loss.
+func tableScore(r tablebase.Result) int {
+	d := r.Distance
+	if d > search.MaxPly {
+		d = search.MaxPly
+	}
+	switch r.WDL {
+	case tablebase.Win:
+		return search.Win - d
+	case tablebase.Loss:
+		return -search.Win + d
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/logging"
+	"github.com/perlmonger42/greedy-bot/search"
+	"github.com/perlmonger42/greedy-bot/tablebase"
+)
+
+// TestTablebaseScore checks that a move from the tablebases is scored by
+// them, rather than by whatever the bot last searched.
+func TestTablebaseScore(t *testing.T) {
+	m, err := tablebase.ParseMaterial("g_v_h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := tablebase.NewGenerator()
+	g.Generate(m)
+	gs := game.NewState("Red", [][]string{
+		{"g", "H", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}, []string{
+		"q", "q", "p", "p", "p", "p", "p", "h", "h", "c", "c", "e", "e", "g", "k",
+		"Q", "Q", "P", "P", "P", "P", "P", "H", "C", "C", "E", "E", "G", "G", "K",
+	})
+
+	// A guard and a soldier against a distant horse: no table covers it,
+	// and the search can't see a win.
+	before := game.NewState("Red", [][]string{
+		{"g", ".", ".", ".", ".", ".", ".", "."},
+		{"p", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "H"},
+	}, []string{
+		"q", "q", "p", "p", "p", "p", "h", "h", "c", "c", "e", "e", "g", "k",
+		"Q", "Q", "P", "P", "P", "P", "P", "H", "C", "C", "E", "E", "G", "G", "K",
+	})
+
+	bot := NewAlphaBetaBot(10*time.Millisecond, 1)
+	bot.Log = logging.New(ioutil.Discard)
+	bot.Searcher().MaxDepth = 2
+	bot.Tablebases = tablebase.NewSet(g.Tables()...)
+	bot.ChooseMove(&before)
+	searched := bot.LastScore()
+	mv := bot.ChooseMove(&gs)
+	if have, want := mv.String(), "RedGuard at A1 takes BlackHorse at B1"; have != want {
+		t.Errorf("expected %s; got %s", want, have)
+	}
+	if have, want := bot.LastScore(), search.Win-1; have != want {
+		t.Errorf("expected the score of a win in 1, %d; got %d (the search scored %d)", want, have, searched)
+	}
+	if last := bot.LastResult().Move; last.String() != mv.String() {
+		t.Errorf("the last result is for %s, not the move played", last.String())
+	}
+}
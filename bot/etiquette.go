@@ -0,0 +1,151 @@
+// Decide when a bot should give up, or settle for a draw.
+package bot
+
+import (
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/tablebase"
+)
+
+// Etiquette decides when a bot should resign, and when it should offer or
+// accept a draw. Evaluations are from the bot's point of view, in the
+// units of its evaluator: a searching bot's LastScore, or else materiel.
+type Etiquette struct {
+	// The bot resigns once ResignAfter of its moves in a row have been
+	// evaluated at or below ResignBelow. If ResignAfter is zero, it only
+	// resigns when it has no legal moves.
+	ResignBelow int `json:"resignBelow"`
+	ResignAfter int `json:"resignAfter"`
+	// The bot accepts a draw when its evaluation is at or below
+	// AcceptBelow, or the material is dead drawn.
+	AcceptBelow int `json:"acceptBelow"`
+	// OfferDraws lets the bot offer a draw when the material is dead
+	// drawn. An offer that is declined isn't repeated until a piece has
+	// been captured. Pao itself has no draw offers, so only set it for a
+	// server that understands the "offerdraw" extension.
+	OfferDraws bool `json:"offerDraws"`
+	// The bot resigns once the server has rejected RejectLimit of its
+	// moves in a row, rather than stall the game. If RejectLimit is zero,
+	// it tries every legal move before giving up.
+	RejectLimit int `json:"rejectLimit"`
+	// Tablebases, if set, recognize drawn endings that DeadDrawn can't.
+	Tablebases *tablebase.Set `json:"-"`
+
+	losing   int // moves in a row evaluated at or below ResignBelow
+	offered  int // pieces on the board when we last offered a draw
+	rejected int // moves in a row the server has rejected
+}
+
+// DefaultEtiquette resigns only when it has no legal moves, or after three
+// moves in a row rejected by the server; never offers a draw; and accepts
+// draws whenever it isn't ahead or the material is dead drawn. Crude
+// materiel is a poor guide to when a game is lost, so resigning on a bad
+// evaluation is left to presets with a searching bot.
+func DefaultEtiquette() Etiquette {
+	return Etiquette{RejectLimit: 3}
+}
+
+// Resign is told the evaluation of each of the bot's moves, and says
+// whether the bot should resign rather than play it.
+func (e *Etiquette) Resign(score int) bool {
+	if score > e.ResignBelow {
+		e.losing = 0
+		return false
+	}
+	e.losing++
+	return e.ResignAfter > 0 && e.losing >= e.ResignAfter
+}
+
+// Rejected is told each time the server rejects one of the bot's moves,
+// and says whether the bot should resign rather than try another.
+func (e *Etiquette) Rejected() bool {
+	e.rejected++
+	return e.RejectLimit > 0 && e.rejected >= e.RejectLimit
+}
+
+// Accepted is told when the server accepts one of the bot's moves.
+func (e *Etiquette) Accepted() {
+	e.rejected = 0
+}
+
+// OfferDraw says whether the bot should offer a draw in gs, its turn.
+func (e *Etiquette) OfferDraw(gs *game.State) bool {
+	if !e.OfferDraws || !e.drawn(gs) {
+		return false
+	}
+	pieces := onBoard(gs)
+	if e.offered != 0 && pieces >= e.offered {
+		return false
+	}
+	e.offered = pieces
+	return true
+}
+
+// AcceptDraw says whether the bot should accept a draw offered in gs,
+// which it evaluated at score.
+func (e *Etiquette) AcceptDraw(gs *game.State, score int) bool {
+	return score <= e.AcceptBelow || e.drawn(gs)
+}
+
+// Reset forgets the previous game.
+func (e *Etiquette) Reset() {
+	e.losing, e.offered, e.rejected = 0, 0, 0
+}
+
+func (e *Etiquette) drawn(gs *game.State) bool {
+	if DeadDrawn(gs) {
+		return true
+	}
+	if e.Tablebases != nil && len(gs.Down) == 0 {
+		r, ok := e.Tablebases.Probe(gs)
+		return ok && r.WDL == tablebase.Draw
+	}
+	return false
+}
+
+// DeadDrawn reports whether neither side can ever capture anything in gs:
+// every piece is face up, there are pieces on both sides, none of them can
+// take any of the other side's by moving next to it, and no cannon has a
+// screen to jump over, since there are only two pieces left.
+func DeadDrawn(gs *game.State) bool {
+	if gs.Us == nil || len(gs.Down) != 0 {
+		return false
+	}
+	var ours, theirs []game.Piece
+	cannons := false
+	for _, row := range gs.Board {
+		for _, p := range row {
+			if p == gs.Us.Q || p == gs.Them.Q {
+				cannons = true
+			}
+			if gs.Us.Contains(p) {
+				ours = append(ours, p)
+			} else if gs.Them.Contains(p) {
+				theirs = append(theirs, p)
+			}
+		}
+	}
+	if len(ours) == 0 || len(theirs) == 0 || cannons && len(ours)+len(theirs) > 2 {
+		return false
+	}
+	for _, a := range ours {
+		for _, b := range theirs {
+			if a.CanTakeIfAdjacent(b) || b.CanTakeIfAdjacent(a) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// onBoard counts the pieces on the board, face up or down.
+func onBoard(gs *game.State) int {
+	n := 0
+	for _, row := range gs.Board {
+		for _, p := range row {
+			if p != game.None {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,114 @@
+package bot_test
+
+import (
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/bot"
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// endgame builds a fully revealed position, red to move, with the given
+// pieces on the first rank and every other piece dead.
+func endgame(pieces ...string) game.State {
+	board := [][]string{
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}
+	left := map[string]int{
+		"q": 2, "p": 5, "h": 2, "c": 2, "e": 2, "g": 2, "k": 1,
+		"Q": 2, "P": 5, "H": 2, "C": 2, "E": 2, "G": 2, "K": 1,
+	}
+	for i, p := range pieces {
+		board[0][2*i] = p
+		left[p]--
+	}
+	dead := []string{}
+	for p, n := range left {
+		for ; n > 0; n-- {
+			dead = append(dead, p)
+		}
+	}
+	return game.NewState("Red", board, dead)
+}
+
+func TestDeadDrawn(t *testing.T) {
+	for _, c := range []struct {
+		pieces []string
+		drawn  bool
+	}{
+		{[]string{"q", "P"}, true},       // a pawn can't take a cannon, which has no screen
+		{[]string{"q", "Q"}, true},       // neither cannon has a screen
+		{[]string{"k", "P"}, false},      // the pawn can take the king
+		{[]string{"q", "q", "P"}, false}, // a cannon can jump the other
+		{[]string{"q"}, false},           // black has lost
+	} {
+		gs := endgame(c.pieces...)
+		if have := bot.DeadDrawn(&gs); have != c.drawn {
+			t.Errorf("%v: expected dead drawn to be %v", c.pieces, c.drawn)
+		}
+	}
+}
+
+func TestEtiquette(t *testing.T) {
+	e := bot.Etiquette{ResignBelow: -1000, ResignAfter: 3, OfferDraws: true}
+	for i, score := range []int{-1200, -1500, 200, -1100, -1100} {
+		if e.Resign(score) {
+			t.Fatalf("move %d: resigned before three losing moves in a row", i)
+		}
+	}
+	if !e.Resign(-1300) {
+		t.Errorf("expected to resign after three losing moves in a row")
+	}
+
+	drawn, open := endgame("q", "P"), endgame("k", "P")
+	if !e.OfferDraw(&drawn) {
+		t.Errorf("expected a draw offer in a dead-drawn position")
+	}
+	if e.OfferDraw(&drawn) {
+		t.Errorf("expected not to repeat the offer until a capture")
+	}
+	if e.OfferDraw(&open) {
+		t.Errorf("expected no draw offer when a capture is possible")
+	}
+	if !e.AcceptDraw(&open, -5) || e.AcceptDraw(&open, 300) || !e.AcceptDraw(&drawn, 300) {
+		t.Errorf("expected to accept draws when behind or dead drawn, and only then")
+	}
+}
+
+func TestDefaultEtiquette(t *testing.T) {
+	e := bot.DefaultEtiquette()
+	for i := 0; i < 10; i++ {
+		if e.Resign(-5000) {
+			t.Fatalf("the default etiquette shouldn't resign on its evaluation")
+		}
+	}
+	drawn := endgame("q", "P")
+	if e.OfferDraw(&drawn) {
+		t.Errorf("the default etiquette shouldn't offer draws")
+	}
+	if !e.AcceptDraw(&drawn, 300) {
+		t.Errorf("the default etiquette should accept a dead draw")
+	}
+}
+
+func TestRejected(t *testing.T) {
+	e := bot.DefaultEtiquette()
+	if e.Rejected() || e.Rejected() {
+		t.Fatalf("resigned before three rejections in a row")
+	}
+	e.Accepted()
+	if e.Rejected() || e.Rejected() {
+		t.Fatalf("an accepted move should restart the count")
+	}
+	if !e.Rejected() {
+		t.Errorf("expected to resign after three rejections in a row")
+	}
+	e.RejectLimit = 0
+	for i := 0; i < 10; i++ {
+		if e.Rejected() {
+			t.Fatalf("resigned with no limit on rejections")
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Implement a Pao bot that searches a fixed number of moves ahead, valuing
+// each flip at the average of the pieces it might turn up.
+package bot
+
+import (
+	"fmt"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/logging"
+	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/search"
+)
+
+type ExpectimaxBot struct {
+	Depth    int             // how many moves ahead to look
+	Log      *logging.Logger // for the bot's reasoning, at debug level
+	searcher *search.Expectimax
+	last     search.Result
+}
+
+func NewExpectimaxBot(depth int) *ExpectimaxBot {
+	return &ExpectimaxBot{
+		Depth:    depth,
+		searcher: search.NewExpectimax(search.Material{}, search.Star2),
+	}
+}
+
+// SetEvaluator makes the bot score positions with eval instead of materiel.
+func (bot *ExpectimaxBot) SetEvaluator(eval search.Evaluator) {
+	bot.searcher = search.NewExpectimax(eval, bot.searcher.Star)
+}
+
+func (bot *ExpectimaxBot) Name() string {
+	return "Expectimax"
+}
+
+func (bot *ExpectimaxBot) ChooseMove(state *game.State) move.T {
+	bot.Log.Debug("choosing a move", "board", &state.Board, "dead", len(state.Dead))
+	bot.last = bot.searcher.Search(state, bot.Depth)
+	bot.Log.Debug("best move", "move", bot.last.Move.String(), "score", bot.last.Score,
+		"nodes", bot.last.Nodes, "elapsed", bot.last.Elapsed)
+	return bot.last.Move
+}
+
+// Explain says why the bot chose its last move.
+func (bot *ExpectimaxBot) Explain() string {
+	return fmt.Sprintf("looking %d moves ahead, averaging over flips, it scored %+d", bot.Depth, bot.last.Score)
+}
+
+// LastScore is the expected score of the most recent move.
+func (bot *ExpectimaxBot) LastScore() int {
+	return bot.last.Score
+}
@@ -5,15 +5,67 @@ package bot
 import (
 	"fmt"
 	"math/rand"
+	"sort"
+	"time"
 
+	"github.com/perlmonger42/greedy-bot/chase"
 	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/logging"
 	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/opening"
+	"github.com/perlmonger42/greedy-bot/opponent"
+	"github.com/perlmonger42/greedy-bot/search"
 )
 
-type GreedyBot struct{}
+type GreedyBot struct {
+	Book *opening.Book // breaks ties among opening flips, if set
+	// Values, if set, is used instead of game.PiecePoints to value
+	// captures and flips. Like game.PiecePoints, red pieces are positive
+	// and black pieces negative.
+	Values map[game.Piece]int
+	// Flips says whether a flip is worth the average of the pieces it
+	// might turn up (the default), the worst of them, or the best.
+	Flips search.FlipPolicy
+	// Tolerance widens the tie among the best moves to every move within
+	// Tolerance points of the best one.
+	Tolerance int
+	// Deterministic breaks ties by taking the first of the tied moves,
+	// rather than one at random.
+	Deterministic bool
+	// Rand picks among tied moves. Each bot has its own, so that its
+	// choices can be reproduced with Reseed; without one, each move
+	// gets a time-seeded source.
+	Rand *rand.Rand
+	// Opponent is what is known of the opponent's play; see SetOpponent.
+	Opponent *opponent.Profile
+	Log      *logging.Logger // for the bot's reasoning, at debug level
+	reason   *string         // why the last move was chosen; see Explain
+}
 
 func NewGreedyBot() GreedyBot {
-	return GreedyBot{}
+	return GreedyBot{
+		Rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		Opponent: &opponent.Profile{},
+		reason:   new(string),
+	}
+}
+
+// SetOpponent tells the bot what is known of its opponent. Against one who
+// tends to ignore threats, it breaks ties in favor of moves that attack.
+// A bot with no Opponent to fill in, like a zero GreedyBot, ignores it.
+func (bot GreedyBot) SetOpponent(p opponent.Profile) {
+	if bot.Opponent != nil {
+		*bot.Opponent = p
+	}
+}
+
+// Reseed restarts the bot's random choices from seed. A bot with no Rand,
+// like a zero GreedyBot, makes its choices with a fresh source each move,
+// and can't be reseeded.
+func (bot GreedyBot) Reseed(seed int64) {
+	if bot.Rand != nil {
+		bot.Rand.Seed(seed)
+	}
 }
 
 func (bot GreedyBot) Name() string {
@@ -21,13 +73,42 @@ func (bot GreedyBot) Name() string {
 }
 
 func (bot GreedyBot) ChooseMove(state *game.State) move.T {
-	fmt.Printf("time to choose a move; state is %v\n", *state)
-	maxer := NewMaximizer(state)
+	bot.Log.Debug("choosing a move", "board", &state.Board, "dead", len(state.Dead))
+	maxer := bot.maximizer(state)
 	move := maxer.BestMove()
-	fmt.Printf("best move is %s\n", move.String())
+	bot.Log.Debug("best move", "move", move.String(), "reason", maxer.reason)
+	if bot.reason != nil {
+		*bot.reason = maxer.reason
+	}
 	return move
 }
 
+// Explain says why the bot chose its last move. The bot must have been
+// made by NewGreedyBot.
+func (bot GreedyBot) Explain() string {
+	if bot.reason == nil {
+		return ""
+	}
+	return *bot.reason
+}
+
+// RankMoves lists every legal move in state, best first, for when the
+// server won't accept the move the bot chose.
+func (bot GreedyBot) RankMoves(state *game.State) []move.T {
+	return bot.maximizer(state).RankedMoves()
+}
+
+func (bot GreedyBot) maximizer(state *game.State) *Maximizer {
+	maxer := NewMaximizer(state)
+	maxer.log = bot.Log
+	maxer.book = bot.Book
+	maxer.values, maxer.flips = bot.Values, bot.Flips
+	maxer.tolerance, maxer.deterministic = bot.Tolerance, bot.Deterministic
+	maxer.rand = bot.Rand
+	maxer.opponent = bot.Opponent
+	return maxer
+}
+
 type Maximizer struct {
 	gs                  *game.State
 	redBlackMultiplier  int
@@ -35,6 +116,15 @@ type Maximizer struct {
 	bestMove            move.T
 	flipScoreCalculated bool
 	flipScore           int
+	book                *opening.Book
+	values              map[game.Piece]int
+	flips               search.FlipPolicy
+	tolerance           int
+	deterministic       bool
+	rand                *rand.Rand // made when first needed, unless given
+	opponent            *opponent.Profile
+	log                 *logging.Logger
+	reason              string // why BestMove chose its move
 }
 
 func NewMaximizer(gs *game.State) *Maximizer {
@@ -55,9 +145,11 @@ func (maxer *Maximizer) BestMove() move.T {
 	//fmt.Printf("found %d possible moves\n", len(moves))
 	bestMoves := []move.T{move.NewQuit()}
 	bestDelta := -1000000
-	for _, m := range moves {
+	deltas := make([]int, len(moves))
+	for i, m := range moves {
 		delta := maxer.scoreDelta(m)
-		fmt.Printf("%d for %s\n", delta, m.String())
+		deltas[i] = delta
+		maxer.log.Debug("scored", "move", m.String(), "delta", delta)
 		if delta > bestDelta {
 			//fmt.Printf("Found new best score: %d for %s\n", delta, m.String())
 			bestDelta = delta
@@ -69,7 +161,117 @@ func (maxer *Maximizer) BestMove() move.T {
 			//fmt.Printf("Found worse move: %d for %s\n", delta, m.String())
 		}
 	}
-	return bestMoves[rand.Intn(len(bestMoves))]
+	if maxer.tolerance > 0 && len(moves) > 0 {
+		bestMoves = nil
+		for i, m := range moves {
+			if deltas[i] >= bestDelta-maxer.tolerance {
+				bestMoves = append(bestMoves, m)
+			}
+		}
+	}
+	if len(bestMoves) == 1 {
+		maxer.reason = fmt.Sprintf("it was worth the most, %+d points", bestDelta)
+	} else if maxer.deterministic {
+		maxer.reason = fmt.Sprintf("it was the first of %d moves worth about %+d points", len(bestMoves), bestDelta)
+	} else {
+		maxer.reason = fmt.Sprintf("%d moves were worth about %+d points, and I picked one at random", len(bestMoves), bestDelta)
+	}
+	if flips := maxer.bookMoves(bestMoves); len(flips) > 0 {
+		bestMoves = flips
+		maxer.reason = "it's the opening book's favorite flip"
+	}
+	if threats := maxer.threatMoves(bestMoves); len(threats) > 0 {
+		bestMoves = threats
+		maxer.reason = "it makes a threat, and you tend to ignore threats"
+	}
+	if m, ok := maxer.chaseMove(bestMoves); ok {
+		maxer.reason = "it chases down one of your last pieces"
+		return m
+	}
+	if maxer.deterministic {
+		return bestMoves[0]
+	}
+	if maxer.rand == nil {
+		maxer.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return bestMoves[maxer.rand.Intn(len(bestMoves))]
+}
+
+// RankedMoves lists the legal moves from best to worst. Moves that score
+// the same keep the order LegalMoves gives them.
+func (maxer *Maximizer) RankedMoves() []move.T {
+	moves := move.LegalMoves(maxer.gs.Us, maxer.gs.Them, maxer.gs.Board)
+	deltas := map[move.T]int{}
+	for _, m := range moves {
+		deltas[m] = maxer.scoreDelta(m)
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return deltas[moves[i]] > deltas[moves[j]]
+	})
+	return moves
+}
+
+// bookMoves narrows a tie among flips early in the game to the ones the
+// opening book likes best.
+func (maxer *Maximizer) bookMoves(tied []move.T) []move.T {
+	if maxer.book == nil || len(tied) < 2 || !maxer.book.Applies(maxer.gs) {
+		return nil
+	}
+	return maxer.book.Best(maxer.gs, tied)
+}
+
+// threatMoves narrows a tie, against an opponent who more often ignores
+// threats than flees them, to the quiet moves that attack one of their
+// pieces without leaving the attacker open to capture.
+func (maxer *Maximizer) threatMoves(tied []move.T) []move.T {
+	p := maxer.opponent
+	if p == nil || !p.Known() || p.IgnoreRate() <= p.FleeRate() || len(tied) < 2 {
+		return nil
+	}
+	threats := []move.T{}
+	for _, m := range tied {
+		if m.Action() != move.Move {
+			continue
+		}
+		next := m.Apply(maxer.gs) // the opponent's turn
+		if len(move.Captures(next.Them, next.Us, next.Board)) == 0 {
+			continue
+		}
+		safe := true
+		for _, reply := range move.Captures(next.Us, next.Them, next.Board) {
+			if reply.To() == m.To() {
+				safe = false
+			}
+		}
+		if safe {
+			threats = append(threats, m)
+		}
+	}
+	return threats
+}
+
+// chaseMove breaks a tie among quiet moves once the board is fully
+// revealed, by hunting down an enemy piece instead of shuffling at random.
+func (maxer *Maximizer) chaseMove(tied []move.T) (move.T, bool) {
+	if len(tied) < 2 || len(maxer.gs.Down) != 0 || maxer.gs.Us == nil {
+		return move.NewQuit(), false
+	}
+	target, ok := chase.PickTarget(maxer.gs)
+	if !ok {
+		return move.NewQuit(), false
+	}
+	plan, ok := chase.Next(maxer.gs, target)
+	if !ok {
+		return move.NewQuit(), false
+	}
+	for _, m := range tied {
+		if m == plan.Move {
+			maxer.log.Debug("chasing", "target", target,
+				"region", plan.Region, "distance", plan.Distance)
+			return m, true
+		}
+	}
+	return move.NewQuit(), false
 }
 
 func (maxer *Maximizer) scoreDelta(m move.T) int {
@@ -81,7 +283,7 @@ func (maxer *Maximizer) scoreDelta(m move.T) int {
 	case move.Move:
 		return 0
 	case move.Take:
-		return -maxer.redBlackMultiplier * game.PiecePoints[m.Killed()]
+		return -maxer.redBlackMultiplier * maxer.points(m.Killed())
 	}
 	return 0
 }
@@ -89,12 +291,38 @@ func (maxer *Maximizer) scoreDelta(m move.T) int {
 func (maxer *Maximizer) computeFlipScore() int {
 	if !maxer.flipScoreCalculated {
 		pointSum, pieceCount := 0, 0
+		worst, best := 1000000, -1000000
 		for piece, count := range maxer.gs.Down {
+			if count == 0 {
+				continue
+			}
 			pieceCount += count
-			pointSum += count * game.PiecePoints[piece]
+			points := maxer.points(piece)
+			pointSum += count * points
+			if v := maxer.redBlackMultiplier * points; v < worst {
+				worst = v
+			}
+			if v := maxer.redBlackMultiplier * points; v > best {
+				best = v
+			}
+		}
+		switch maxer.flips {
+		case search.FlipPessimistic:
+			maxer.flipScore = worst
+		case search.FlipOptimistic:
+			maxer.flipScore = best
+		default:
+			maxer.flipScore = maxer.redBlackMultiplier * pointSum / pieceCount
 		}
-		maxer.flipScore = maxer.redBlackMultiplier * pointSum / pieceCount
 		maxer.flipScoreCalculated = true
 	}
 	return maxer.flipScore
 }
+
+// points is the value of p, from the bot's own table if it has one.
+func (maxer *Maximizer) points(p game.Piece) int {
+	if maxer.values != nil {
+		return maxer.values[p]
+	}
+	return game.PiecePoints[p]
+}
@@ -0,0 +1,83 @@
+package bot_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/bot"
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/opponent"
+	"github.com/perlmonger42/greedy-bot/selfplay"
+)
+
+// TestGreedyReseed checks that reseeding replays a game move for move.
+func TestGreedyReseed(t *testing.T) {
+	deal := selfplay.NewDeal(rand.New(rand.NewSource(3)))
+	play := func(seed int64) []string {
+		red, black := bot.NewGreedyBot(), bot.NewGreedyBot()
+		red.Reseed(seed)
+		black.Reseed(seed + 1)
+		moves := []string{}
+		for _, ply := range selfplay.Play(red, black, deal, selfplay.Options{}).Plies {
+			moves = append(moves, ply.Move.String())
+		}
+		return moves
+	}
+	first, again, other := play(5), play(5), play(6)
+	if len(first) != len(again) {
+		t.Fatalf("the replay had %d plies instead of %d", len(again), len(first))
+	}
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("ply %d: %s was replayed as %s", i, first[i], again[i])
+		}
+	}
+	same := len(first) == len(other)
+	for i := 0; same && i < len(first); i++ {
+		same = first[i] == other[i]
+	}
+	if same {
+		t.Errorf("a different seed should play a different game")
+	}
+}
+
+func TestGreedyRankMoves(t *testing.T) {
+	// Red's cart can take the black horse, take the black pawn, or move.
+	gs := game.NewState("Red", [][]string{
+		{".", "H", ".", ".", ".", ".", ".", "."},
+		{"P", "c", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}, []string{})
+	ranked := bot.NewGreedyBot().RankMoves(&gs)
+	if len(ranked) != 4 {
+		t.Fatalf("ranked %d moves, want the cart's 4", len(ranked))
+	}
+	if ranked[0].Action() != move.Take || ranked[0].Killed() != game.BlackHorse {
+		t.Errorf("best move is %s, want the horse's capture", ranked[0].String())
+	}
+	if ranked[1].Action() != move.Take || ranked[1].Killed() != game.BlackPawn {
+		t.Errorf("second-best move is %s, want the pawn's capture", ranked[1].String())
+	}
+}
+
+// TestZeroGreedyBot checks that a GreedyBot not made by NewGreedyBot can
+// still be reseeded, told about its opponent, and asked for moves.
+func TestZeroGreedyBot(t *testing.T) {
+	var b bot.GreedyBot
+	b.Reseed(1)
+	b.SetOpponent(opponent.Profile{Games: 1})
+	gs := game.NewState("Red", [][]string{
+		{".", "H", ".", ".", ".", ".", ".", "."},
+		{"P", "c", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}, []string{})
+	if m := b.ChooseMove(&gs); m.Action() != move.Take || m.Killed() != game.BlackHorse {
+		t.Errorf("chose %s, want the horse's capture", m.String())
+	}
+	if b.Explain() != "" {
+		t.Errorf("a zero bot has nowhere to keep its reasons, but explained %q", b.Explain())
+	}
+}
@@ -0,0 +1,221 @@
+// Package chase plans the hunt for an enemy piece once the board is
+// fully revealed, when there is nothing left to flip and every quiet move
+// looks the same to a materiel count.
+//
+// The planner races the target against our hunters (the pieces that can
+// take it) on the 4x8 grid. A square is safe for the target if it can get
+// there before any hunter can; the target's safe region is every square it
+// can reach through safe squares. A good chasing move shrinks that region,
+// and once it is empty the target is lost.
+//
+// Tempo matters too. Every move changes the distance between two pieces by
+// one, so after each of our hunter moves the distance to the target has
+// the same parity. When it is even, the target can never step next to a
+// hunter safely, and it can be driven into a corner; when it is odd, the
+// target can keep its distance. A move by some other piece (a tempo move)
+// flips the parity, so the planner prefers moves that leave it even.
+package chase
+
+import (
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// Plan is the planner's advice for the side to move.
+type Plan struct {
+	Target   move.Location
+	Move     move.T
+	Region   int  // size of the target's safe region after Move
+	Distance int  // the nearest hunter's distance to the target after Move
+	Tempo    bool // Move is by some piece other than a hunter
+}
+
+// Hunters returns the squares of our face-up pieces that could take the
+// piece at target if they were next to it. Cannons never qualify, since
+// they can't take an adjacent piece.
+func Hunters(gs *game.State, target move.Location) []move.Location {
+	victim := gs.Board.At(target.Row(), target.Col())
+	hunters := []move.Location{}
+	for r, row := range gs.Board {
+		for c, p := range row {
+			if gs.Us.Contains(p) && p.CanTakeIfAdjacent(victim) {
+				hunters = append(hunters, move.NewLocation(r, c))
+			}
+		}
+	}
+	return hunters
+}
+
+// PickTarget chooses the most valuable enemy piece that some piece of ours
+// can hunt. It reports false if there is no such piece.
+func PickTarget(gs *game.State) (move.Location, bool) {
+	best, bestValue, found := move.Location{}, -1, false
+	for r, row := range gs.Board {
+		for c, p := range row {
+			if !gs.Them.Contains(p) {
+				continue
+			}
+			loc := move.NewLocation(r, c)
+			value := game.PiecePoints[p]
+			if value < 0 {
+				value = -value
+			}
+			if value > bestValue && len(Hunters(gs, loc)) > 0 {
+				best, bestValue, found = loc, value, true
+			}
+		}
+	}
+	return best, found
+}
+
+// Next plans our next move in the hunt for the piece at target. It takes
+// the target if it can; otherwise it chooses among our sliding moves that
+// don't leave the moved piece where the enemy can take it, preferring the
+// smallest safe region for the target, then even tempo, then the shortest
+// distance. It reports false if we have no hunter or no such move.
+func Next(gs *game.State, target move.Location) (Plan, bool) {
+	if gs.Us == nil || !gs.Them.Contains(gs.Board.At(target.Row(), target.Col())) {
+		return Plan{}, false
+	}
+	hunters := Hunters(gs, target)
+	if len(hunters) == 0 {
+		return Plan{}, false
+	}
+
+	moves := move.LegalMoves(gs.Us, gs.Them, gs.Board)
+	for _, m := range moves {
+		if m.Action() == move.Take && m.To() == target {
+			return Plan{Target: target, Move: m}, true
+		}
+	}
+
+	var best Plan
+	found := false
+	for _, m := range moves {
+		if m.Action() != move.Move || hangs(gs, m) {
+			continue
+		}
+		next := m.Apply(gs)
+		plan := Plan{Target: target, Move: m, Tempo: !isHunter(hunters, m.From())}
+		newHunters := hunters
+		if !plan.Tempo {
+			newHunters = moveHunter(hunters, m)
+		}
+		plan.Region, plan.Distance = race(&next, target, newHunters)
+		if !found || better(plan, best) {
+			best, found = plan, true
+		}
+	}
+	return best, found
+}
+
+// better tells whether plan a is preferable to plan b.
+func better(a, b Plan) bool {
+	if a.Region != b.Region {
+		return a.Region < b.Region
+	}
+	if evenA, evenB := a.Distance%2 == 0, b.Distance%2 == 0; evenA != evenB {
+		return evenA
+	}
+	return a.Distance < b.Distance
+}
+
+// hangs tells whether the piece moved by m could be taken by the enemy
+// right away.
+func hangs(gs *game.State, m move.T) bool {
+	next := m.Apply(gs)
+	for _, reply := range move.Captures(next.Us, next.Them, next.Board) {
+		if reply.To() == m.To() {
+			return true
+		}
+	}
+	return false
+}
+
+func isHunter(hunters []move.Location, loc move.Location) bool {
+	for _, h := range hunters {
+		if h == loc {
+			return true
+		}
+	}
+	return false
+}
+
+func moveHunter(hunters []move.Location, m move.T) []move.Location {
+	moved := make([]move.Location, len(hunters))
+	for i, h := range hunters {
+		if h == m.From() {
+			h = m.To()
+		}
+		moved[i] = h
+	}
+	return moved
+}
+
+// race computes the target's safe region in gs, where the target is to
+// move, and the distance from the nearest hunter to the target.
+func race(gs *game.State, target move.Location, hunters []move.Location) (region, distance int) {
+	// Hunters can't move through other pieces, but they can move onto any
+	// square the target might run to.
+	blocked := func(r, c int) bool {
+		return gs.Board[r][c] != game.None && (r != target.Row() || c != target.Col())
+	}
+	starts := []int{}
+	for _, h := range hunters {
+		starts = append(starts, h.Row()*8+h.Col())
+	}
+	hunterDist := bfs(starts, blocked, nil)
+	distance = hunterDist[target.Row()*8+target.Col()]
+
+	// The target moves first, so it is safe anywhere it arrives strictly
+	// before every hunter could.
+	targetDist := bfs([]int{target.Row()*8 + target.Col()}, func(r, c int) bool {
+		return gs.Board[r][c] != game.None
+	}, func(sq, d int) bool {
+		return d < hunterDist[sq]
+	})
+	for _, d := range targetDist {
+		if d > 0 && d < unreachable {
+			region++
+		}
+	}
+	return region, distance
+}
+
+const unreachable = 1 << 20
+
+// bfs returns the distance from the nearest start to every square, moving
+// orthogonally through squares that aren't blocked. If allowed is given, a
+// square is only entered if allowed says it may be at that distance.
+func bfs(starts []int, blocked func(r, c int) bool, allowed func(sq, d int) bool) [32]int {
+	dist := [32]int{}
+	for i := range dist {
+		dist[i] = unreachable
+	}
+	queue := []int{}
+	for _, sq := range starts {
+		dist[sq] = 0
+		queue = append(queue, sq)
+	}
+	for len(queue) > 0 {
+		sq := queue[0]
+		queue = queue[1:]
+		r, c := sq/8, sq%8
+		for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			r2, c2 := r+d[0], c+d[1]
+			if r2 < 0 || r2 >= 4 || c2 < 0 || c2 >= 8 || blocked(r2, c2) {
+				continue
+			}
+			next := r2*8 + c2
+			if dist[next] != unreachable {
+				continue
+			}
+			if allowed != nil && !allowed(next, dist[sq]+1) {
+				continue
+			}
+			dist[next] = dist[sq] + 1
+			queue = append(queue, next)
+		}
+	}
+	return dist
+}
@@ -0,0 +1,77 @@
+package chase
+
+import (
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/tablebase"
+)
+
+func TestNextTakesTarget(t *testing.T) {
+	gs := game.NewState("Red", [][]string{
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", "g", "H", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}, []string{})
+	target, ok := PickTarget(&gs)
+	if !ok || target.String() != "C2" {
+		t.Fatalf("expected to target the horse at C2; got %s", target.String())
+	}
+	plan, ok := Next(&gs, target)
+	if !ok || plan.Move.Action() != move.Take {
+		t.Errorf("expected to take the horse; got %s", plan.Move.String())
+	}
+}
+
+// TestChaseWinsWonEndings plays the planner against a perfect defender in
+// every position the tablebase says a lone guard wins against a horse.
+func TestChaseWinsWonEndings(t *testing.T) {
+	m, _ := tablebase.ParseMaterial("g_v_h")
+	g := tablebase.NewGenerator()
+	g.Generate(m)
+	tables := tablebase.NewSet(g.Tables()...)
+
+	for gsq := 0; gsq < 32; gsq++ {
+		for hsq := 0; hsq < 32; hsq++ {
+			if gsq == hsq {
+				continue
+			}
+			gs := game.State{Us: &game.RedTeam, Them: &game.BlackTeam}
+			gs.Board[gsq/8][gsq%8] = game.RedGuard
+			gs.Board[hsq/8][hsq%8] = game.BlackHorse
+			if r, _ := tables.Probe(&gs); r.WDL != tablebase.Win {
+				continue
+			}
+			if !chaseDown(&gs, tables, 60) {
+				t.Errorf("guard at %d failed to catch horse at %d", gsq, hsq)
+			}
+		}
+	}
+}
+
+func chaseDown(gs *game.State, defender *tablebase.Set, plies int) bool {
+	for ply := 0; ply < plies; ply++ {
+		var m move.T
+		if gs.Us == &game.RedTeam {
+			target, ok := PickTarget(gs)
+			if !ok {
+				return false
+			}
+			plan, ok := Next(gs, target)
+			if !ok {
+				return false
+			}
+			m = plan.Move
+		} else {
+			m, _, _ = defender.BestMove(gs)
+		}
+		if m.Action() == move.Take {
+			return true
+		}
+		next := m.Apply(gs)
+		gs = &next
+	}
+	return false
+}
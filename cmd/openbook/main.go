@@ -0,0 +1,54 @@
+// Learn an opening book of flip preferences by self-play.
+//
+// Usage:
+//
+//	openbook -games 1000 -out book.json
+//
+// If the output file already exists, its statistics are extended rather
+// than replaced.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+
+	"github.com/perlmonger42/greedy-bot/bot"
+	"github.com/perlmonger42/greedy-bot/opening"
+	"github.com/perlmonger42/greedy-bot/selfplay"
+)
+
+func main() {
+	games := flag.Int("games", 1000, "number of self-play games to learn from")
+	out := flag.String("out", "book.json", "file to write the book into")
+	seed := flag.Int64("seed", 1, "seed for dealing the pieces and the bots' choices")
+	flag.Parse()
+
+	book, err := opening.Load(*out)
+	if os.IsNotExist(err) {
+		book = opening.NewBook()
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	r := rand.New(rand.NewSource(*seed))
+	records := []selfplay.Record{}
+	for i := 0; i < *games; i++ {
+		// Both sides break ties among their opening flips by the book
+		// as it stands, so each run reinforces what it already prefers.
+		red, black := bot.NewGreedyBot(), bot.NewGreedyBot()
+		red.Book, black.Book = book, book
+		red.Reseed(r.Int63())
+		black.Reseed(r.Int63())
+		records = append(records,
+			selfplay.Play(red, black, selfplay.NewDeal(r), selfplay.Options{}))
+	}
+	book.Learn(records)
+	if err := book.Save(*out); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("learned from %d games; wrote %s\n", len(records), *out)
+}
@@ -0,0 +1,221 @@
+// Play bots against each other from random deals, and write every position
+// of every game to a JSON Lines dataset, for tuning and training.
+//
+// Usage:
+//
+//	selfplay -a alphabeta:3 -b greedy -games 1000 -workers 8 -out games.jsonl
+//
+// A bot is named as "greedy", "alphabeta", or "expectimax", optionally
+// followed by a search depth or time budget: "alphabeta:4",
+// "alphabeta:250ms", "expectimax:2". Bot a moves first in even-numbered
+// games, and bot b in odd-numbered ones. The search bots evaluate positions
+// by materiel, unless -neta or -netb gives them a value network to use.
+//
+// Game n is dealt, and its bots' random choices seeded, from the seed plus
+// n, so a dataset can be regenerated exactly (unless the bots search to a
+// time budget, or with several workers). If the output
+// file already exists, the games it holds are kept and skipped, so an
+// interrupted run can simply be restarted.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/bot"
+	"github.com/perlmonger42/greedy-bot/dataset"
+	"github.com/perlmonger42/greedy-bot/nn"
+	"github.com/perlmonger42/greedy-bot/search"
+	"github.com/perlmonger42/greedy-bot/selfplay"
+)
+
+func main() {
+	a := flag.String("a", "greedy", "bot that moves first in even-numbered games")
+	b := flag.String("b", "greedy", "bot that moves first in odd-numbered games")
+	games := flag.Int("games", 100, "number of games to play")
+	workers := flag.Int("workers", 1, "number of games to play at once")
+	seed := flag.Int64("seed", 1, "seed for dealing the pieces and the bots' choices")
+	out := flag.String("out", "selfplay.jsonl", "file to write the dataset into")
+	maxQuiet := flag.Int("maxquiet", selfplay.DefaultMaxQuietPlies,
+		"plies without a flip or capture before a game is drawn")
+	netA := flag.String("neta", "", "value network for bot a")
+	netB := flag.String("netb", "", "value network for bot b")
+	flag.Parse()
+	players := [2]player{{spec: *a}, {spec: *b}}
+	for i, path := range []string{*netA, *netB} {
+		if path != "" {
+			net, err := nn.Load(path)
+			if err != nil {
+				fail(err)
+			}
+			players[i].eval = nn.NewEvaluator(net)
+		}
+		if _, err := players[i].newBot(); err != nil {
+			fail(err)
+		}
+	}
+
+	done, err := resume(*out)
+	if err != nil {
+		fail(err)
+	}
+	f, err := os.OpenFile(*out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fail(err)
+	}
+	defer f.Close()
+	fmt.Fprintf(os.Stderr, "%s: %d games already done\n", *out, len(done))
+
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	opt := selfplay.Options{MaxQuietPlies: *maxQuiet}
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				start := time.Now()
+				rec := play(n, players, *seed, opt)
+				var buf bytes.Buffer
+				positions := rec.Positions(n)
+				for i := range positions {
+					dataset.Write(&buf, &positions[i])
+				}
+				mu.Lock()
+				_, err := f.Write(buf.Bytes())
+				mu.Unlock()
+				if err != nil {
+					fail(err)
+				}
+				fmt.Fprintf(os.Stderr, "game %d: %d plies, winner %q (%v)\n",
+					n, len(rec.Plies), rec.Winner, time.Since(start))
+			}
+		}()
+	}
+	for n := 0; n < *games; n++ {
+		if !done[n] {
+			jobs <- n
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// play plays game n between fresh bots, so that nothing carries over from
+// one game to the next. The bots' random choices are seeded along with the
+// deal.
+func play(n int, players [2]player, seed int64, opt selfplay.Options) selfplay.Record {
+	r := rand.New(rand.NewSource(seed + int64(n)))
+	deal := selfplay.NewDeal(r)
+	first, _ := players[0].newBot()
+	second, _ := players[1].newBot()
+	for _, b := range []selfplay.Bot{first, second} {
+		if reseeder, ok := b.(interface{ Reseed(int64) }); ok {
+			reseeder.Reseed(r.Int63())
+		}
+	}
+	if n%2 == 1 {
+		first, second = second, first
+	}
+	return selfplay.Play(first, second, deal, opt)
+}
+
+// player describes one of the bots: its specification on the command line,
+// and the evaluator it searches with, if not materiel.
+type player struct {
+	spec string
+	eval search.Evaluator
+}
+
+// newBot builds the player's bot.
+func (pl player) newBot() (selfplay.Bot, error) {
+	spec := pl.spec
+	name, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+	depth, budget := 0, time.Duration(0)
+	if arg != "" {
+		var err error
+		if depth, err = strconv.Atoi(arg); err != nil {
+			if budget, err = time.ParseDuration(arg); err != nil {
+				return nil, fmt.Errorf("bot %q: expected a depth or a duration after the colon", spec)
+			}
+		}
+	}
+	switch name {
+	case "greedy":
+		return bot.NewGreedyBot(), nil
+	case "alphabeta":
+		if depth == 0 && budget == 0 {
+			depth = 3
+		}
+		ab := bot.NewAlphaBetaBot(budget, 1)
+		ab.Searcher().MaxDepth = depth
+		if pl.eval != nil {
+			ab.Searcher().Eval = pl.eval
+		}
+		return ab, nil
+	case "expectimax":
+		if depth == 0 {
+			depth = 2
+		}
+		em := bot.NewExpectimaxBot(depth)
+		if pl.eval != nil {
+			em.SetEvaluator(pl.eval)
+		}
+		return em, nil
+	}
+	return nil, fmt.Errorf("bot %q: unknown bot %q", spec, name)
+}
+
+// resume finds the games already completed in the dataset at path. Each
+// game is written in one piece, so only the last can be incomplete, if an
+// earlier run was interrupted; resume truncates the file to remove it.
+func resume(path string) (map[int]bool, error) {
+	done := map[int]bool{}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return done, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counts := map[int]int{}
+	r := bufio.NewReader(f)
+	offset, good := int64(0), int64(0)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		offset += int64(len(line))
+		var p dataset.Position
+		if json.Unmarshal(line, &p) != nil {
+			break
+		}
+		if counts[p.Game]++; counts[p.Game] == p.Plies {
+			done[p.Game], good = true, offset
+		}
+	}
+	return done, f.Truncate(good)
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,51 @@
+// Generate endgame tablebases for the bots to consult.
+//
+// Usage:
+//
+//	tbgen -dir tables kg_v_c gp_v_h ...
+//
+// Each argument names a material: the red pieces, then the black pieces,
+// as lowercase Pao descriptors. Every smaller table that the named ones
+// depend on is generated and saved too.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/tablebase"
+)
+
+func main() {
+	dir := flag.String("dir", "tablebases", "directory to write the tables into")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: tbgen [-dir DIR] MATERIAL...\n")
+		os.Exit(2)
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	g := tablebase.NewGenerator()
+	for _, name := range flag.Args() {
+		m, err := tablebase.ParseMaterial(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		start := time.Now()
+		g.Generate(m)
+		fmt.Printf("generated %s in %v\n", m.Name(), time.Since(start))
+	}
+	for _, t := range g.Tables() {
+		if err := t.Save(*dir); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("wrote %s\n", tablebase.FileName(t.Material))
+	}
+}
@@ -0,0 +1,96 @@
+// Train a value network on a dataset of positions with known outcomes, such
+// as the selfplay command writes, and save it for the search bots to use.
+//
+// Usage:
+//
+//	train -data games.jsonl -out net.json [-init net.json] [-hidden 64,32]
+//
+// Every holdout'th game is kept out of training, and the network's loss on
+// those games is reported alongside its loss on the rest, as a check on
+// overfitting.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/perlmonger42/greedy-bot/dataset"
+	"github.com/perlmonger42/greedy-bot/nn"
+)
+
+func main() {
+	data := flag.String("data", "", "JSON Lines file of positions")
+	out := flag.String("out", "net.json", "file to write the trained network into")
+	init := flag.String("init", "", "network to continue training (default: a new one)")
+	hidden := flag.String("hidden", "64,32", "sizes of the hidden layers of a new network")
+	epochs := flag.Int("epochs", 20, "passes over the training positions")
+	rate := flag.Float64("rate", 0.01, "learning rate")
+	batch := flag.Int("batch", 32, "positions per gradient step")
+	holdout := flag.Int("holdout", 10, "keep every nth game out of training (0 for none)")
+	seed := flag.Int64("seed", 1, "seed for initializing and shuffling")
+	flag.Parse()
+	if *data == "" {
+		fmt.Fprintf(os.Stderr, "usage: train -data FILE [-out FILE] [-init FILE]\n")
+		os.Exit(2)
+	}
+
+	r := rand.New(rand.NewSource(*seed))
+	var net *nn.Network
+	if *init != "" {
+		var err error
+		if net, err = nn.Load(*init); err != nil {
+			fail(err)
+		}
+	} else {
+		sizes := []int{}
+		for _, s := range strings.Split(*hidden, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || n <= 0 {
+				fail(fmt.Errorf("bad hidden layer size %q", s))
+			}
+			sizes = append(sizes, n)
+		}
+		net = nn.NewNetwork(r, sizes...)
+	}
+
+	f, err := os.Open(*data)
+	if err != nil {
+		fail(err)
+	}
+	positions, err := dataset.Read(f)
+	f.Close()
+	if err != nil {
+		fail(fmt.Errorf("%s: %v", *data, err))
+	}
+	var training, validation []dataset.Position
+	for _, p := range positions {
+		if *holdout > 0 && p.Game%*holdout == 0 {
+			validation = append(validation, p)
+		} else {
+			training = append(training, p)
+		}
+	}
+	trainSet, validSet := nn.NewSamples(training), nn.NewSamples(validation)
+
+	t := nn.NewTrainer(net)
+	t.Rate, t.Batch = *rate, *batch
+	fmt.Printf("%d training and %d validation positions; network %v\n",
+		len(trainSet), len(validSet), net.Sizes)
+	fmt.Printf("starting loss %.6f, validation %.6f\n", t.Loss(trainSet), t.Loss(validSet))
+	t.Progress = func(epoch int, loss float64) {
+		fmt.Printf("epoch %d: loss %.6f, validation %.6f\n", epoch, loss, t.Loss(validSet))
+	}
+	t.Train(trainSet, *epochs, r)
+	if err := net.Save(*out); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,65 @@
+// Fit piece values and evaluation weights to a dataset of positions with
+// known outcomes, and write them to a weights file the bots can load.
+//
+// Usage:
+//
+//	tune -data positions.jsonl -out weights.json [-init weights.json]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/perlmonger42/greedy-bot/dataset"
+	"github.com/perlmonger42/greedy-bot/search"
+	"github.com/perlmonger42/greedy-bot/tune"
+)
+
+func main() {
+	data := flag.String("data", "", "JSON Lines file of positions")
+	out := flag.String("out", "weights.json", "file to write the tuned weights into")
+	init := flag.String("init", "", "weights to start from (default: game.PiecePoints)")
+	passes := flag.Int("passes", 200, "maximum passes over the weights")
+	flag.Parse()
+	if *data == "" {
+		fmt.Fprintf(os.Stderr, "usage: tune -data FILE [-out FILE] [-init FILE]\n")
+		os.Exit(2)
+	}
+
+	start := search.DefaultWeights()
+	if *init != "" {
+		var err error
+		if start, err = search.LoadWeights(*init); err != nil {
+			fail(err)
+		}
+	}
+	f, err := os.Open(*data)
+	if err != nil {
+		fail(err)
+	}
+	positions, err := dataset.Read(f)
+	f.Close()
+	if err != nil {
+		fail(fmt.Errorf("%s: %v", *data, err))
+	}
+
+	t := tune.NewTuner(tune.NewSamples(positions))
+	t.Fixed[0] = true // cannons keep their value, fixing the scale
+	k := t.FitK(start.Vector())
+	fmt.Printf("%d positions; K = %g; starting error %.6f\n",
+		len(positions), k, t.Error(start.Vector()))
+	t.Progress = func(pass int, e float64, w search.Weights) {
+		fmt.Printf("pass %d: error %.6f %v\n", pass, e, w)
+	}
+	w, e := t.Tune(start, *passes)
+	fmt.Printf("final error %.6f\n", e)
+	if err := w.Save(*out); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,163 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A Registry maps each message's Action to the type that carries it.
+// Every type registered must be a struct with a string field named Action.
+type Registry struct {
+	types map[string]reflect.Type
+}
+
+func NewRegistry() *Registry {
+	return &Registry{types: map[string]reflect.Type{}}
+}
+
+// Register makes prototype's type the type of messages with the given
+// actions. The prototype may be a struct or a pointer to one. Register
+// panics if the type has no Action field, or an action is already taken.
+func (r *Registry) Register(prototype interface{}, actions ...string) {
+	t := reflect.TypeOf(prototype)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("command: can't register %T: not a struct", prototype))
+	}
+	if f, ok := t.FieldByName("Action"); !ok || f.Type.Kind() != reflect.String {
+		panic(fmt.Sprintf("command: can't register %v: no Action string field", t))
+	}
+	for _, action := range actions {
+		if old, ok := r.types[action]; ok {
+			panic(fmt.Sprintf("command: action %q is already registered to %v", action, old))
+		}
+		r.types[action] = t
+	}
+}
+
+// Lookup finds the type registered for action.
+func (r *Registry) Lookup(action string) (reflect.Type, bool) {
+	t, ok := r.types[action]
+	return t, ok
+}
+
+// Actions lists the registered actions, in order.
+func (r *Registry) Actions() []string {
+	actions := []string{}
+	for action := range r.types {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	return actions
+}
+
+// ServerMessages are the messages a Pao server sends its clients.
+var ServerMessages = NewRegistry()
+
+// ClientMessages are the messages a client, such as the bot, sends a Pao
+// server.
+var ClientMessages = NewRegistry()
+
+// The draw messages, "offerdraw", "acceptdraw" and "declinedraw", aren't
+// part of Pao's protocol; they're an extension for servers that support
+// draws. The bot only offers a draw if its etiquette's OfferDraws is set,
+// which it isn't by default, and only answers offers a server makes.
+func init() {
+	ServerMessages.Register(ChatCommand{}, "chat")
+	ServerMessages.Register(BoardCommand{}, "board")
+	ServerMessages.Register(ColorCommand{}, "color")
+	ServerMessages.Register(GameOverCommand{}, "gameover")
+	ServerMessages.Register(DrawCommand{}, "offerdraw", "acceptdraw", "declinedraw")
+	ServerMessages.Register(ErrorCommand{}, "error")
+
+	ClientMessages.Register(Command{}, "move", "resign", "chat")
+	ClientMessages.Register(DrawCommand{}, "offerdraw", "acceptdraw", "declinedraw")
+}
+
+// ErrUnknownAction is the error for a message whose Action isn't
+// registered, when that matters.
+var ErrUnknownAction = errors.New("unknown action")
+
+// DecodeError reports a message that couldn't be decoded or encoded.
+type DecodeError struct {
+	Action string // the message's Action, if it could be read
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Action == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s message: %v", e.Action, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// A Codec converts messages to and from JSON, by way of a Registry.
+//
+// A lenient codec, the default, decodes a message with an unregistered
+// Action as a Command, and ignores fields its type doesn't have. A strict
+// one rejects both, and only encodes messages whose Action is registered
+// to their type.
+type Codec struct {
+	Registry *Registry
+	Strict   bool
+}
+
+// Decode returns a pointer to a new message of the type registered for
+// data's Action. Its error, if any, is a *DecodeError.
+func (c Codec) Decode(data []byte) (interface{}, error) {
+	var envelope struct{ Action string }
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	t, ok := c.Registry.Lookup(envelope.Action)
+	if !ok && c.Strict {
+		return nil, &DecodeError{Action: envelope.Action, Err: ErrUnknownAction}
+	} else if !ok {
+		t = reflect.TypeOf(Command{})
+	}
+	msg := reflect.New(t).Interface()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if c.Strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(msg); err != nil {
+		return nil, &DecodeError{Action: envelope.Action, Err: err}
+	}
+	return msg, nil
+}
+
+// Encode returns the JSON for msg, a message or a pointer to one. Its
+// error, if any, is a *DecodeError.
+func (c Codec) Encode(msg interface{}) ([]byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(msg))
+	if v.Kind() != reflect.Struct {
+		return nil, &DecodeError{Err: fmt.Errorf("%T is not a message", msg)}
+	}
+	action := v.FieldByName("Action")
+	if !action.IsValid() || action.Kind() != reflect.String {
+		return nil, &DecodeError{Err: fmt.Errorf("%T has no Action", msg)}
+	}
+	if c.Strict {
+		if t, ok := c.Registry.Lookup(action.String()); !ok {
+			return nil, &DecodeError{Action: action.String(), Err: ErrUnknownAction}
+		} else if t != v.Type() {
+			return nil, &DecodeError{Action: action.String(),
+				Err: fmt.Errorf("is a %v, not a %v", t, v.Type())}
+		}
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, &DecodeError{Action: action.String(), Err: err}
+	}
+	return data, nil
+}
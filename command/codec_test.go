@@ -0,0 +1,127 @@
+package command
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	row := []string{"?", ".", "q", "K", "?", "?", ".", "P"}
+	for _, test := range []struct {
+		registry *Registry
+		msg      interface{}
+	}{
+		{ServerMessages, &ChatCommand{Action: "chat", Player: "alice", Color: "Red", Message: "!hint", Auth: true}},
+		{ServerMessages, &BoardCommand{Action: "board", Board: [][]string{row, row, row, row},
+			Dead: []string{"p", "E"}, LastMove: []string{"B3", "B4"}, LastDead: "E", YourTurn: true,
+			WhoseTurn: "bob", TurnColor: "Black", NumPlayers: 2}},
+		{ServerMessages, &ColorCommand{Action: "color", Color: "Black"}},
+		{ServerMessages, &GameOverCommand{Action: "gameover", Message: "Red wins", YouWin: true}},
+		{ServerMessages, &DrawCommand{Action: "offerdraw", Color: "Red"}},
+		{ServerMessages, &ErrorCommand{Action: "error", Message: "not your turn"}},
+		{ClientMessages, &Command{Action: "move", Argument: "B3>B4"}},
+		{ClientMessages, &Command{Action: "resign"}},
+		{ClientMessages, &Command{Action: "chat", Argument: "gg"}},
+		{ClientMessages, &DrawCommand{Action: "declinedraw", Color: "Black"}},
+	} {
+		for _, strict := range []bool{false, true} {
+			codec := Codec{Registry: test.registry, Strict: strict}
+			data, err := codec.Encode(test.msg)
+			if err != nil {
+				t.Errorf("Encode(%+v) (strict %v): %v", test.msg, strict, err)
+				continue
+			}
+			got, err := codec.Decode(data)
+			if err != nil {
+				t.Errorf("Decode(%s) (strict %v): %v", data, strict, err)
+			} else if !reflect.DeepEqual(got, test.msg) {
+				t.Errorf("round trip (strict %v) of %+v gave %+v", strict, test.msg, got)
+			}
+		}
+	}
+}
+
+func TestLenientAndStrict(t *testing.T) {
+	lenient := Codec{Registry: ServerMessages}
+	strict := Codec{Registry: ServerMessages, Strict: true}
+
+	extra := []byte(`{"Action": "color", "Color": "Red", "Room": 7}`)
+	if got, err := lenient.Decode(extra); err != nil {
+		t.Errorf("lenient Decode of an extra field: %v", err)
+	} else if cc, ok := got.(*ColorCommand); !ok || cc.Color != "Red" {
+		t.Errorf("lenient Decode of an extra field gave %#v", got)
+	}
+	if _, err := strict.Decode(extra); err == nil {
+		t.Errorf("strict Decode should reject an extra field")
+	}
+
+	unknown := []byte(`{"Action": "spectate", "Argument": "room 7"}`)
+	if got, err := lenient.Decode(unknown); err != nil {
+		t.Errorf("lenient Decode of an unknown action: %v", err)
+	} else if c, ok := got.(*Command); !ok || c.Argument != "room 7" {
+		t.Errorf("lenient Decode of an unknown action gave %#v", got)
+	}
+	var derr *DecodeError
+	if _, err := strict.Decode(unknown); !errors.As(err, &derr) || derr.Action != "spectate" ||
+		!errors.Is(err, ErrUnknownAction) {
+		t.Errorf("strict Decode of an unknown action: got %v, want ErrUnknownAction", err)
+	}
+
+	if _, err := lenient.Decode([]byte(`{"Action": `)); !errors.As(err, &derr) {
+		t.Errorf("Decode of bad JSON: got %v, want a *DecodeError", err)
+	}
+	if _, err := lenient.Decode([]byte(`{"Action": "color", "Color": 7}`)); !errors.As(err, &derr) ||
+		derr.Action != "color" {
+		t.Errorf("Decode of a mistyped field: got %v, want a *DecodeError for color", err)
+	}
+
+	wrongType := ColorCommand{Action: "board", Color: "Red"}
+	if _, err := lenient.Encode(wrongType); err != nil {
+		t.Errorf("lenient Encode: %v", err)
+	}
+	if _, err := strict.Encode(wrongType); err == nil {
+		t.Errorf("strict Encode should reject a board action on a ColorCommand")
+	}
+	if _, err := lenient.Encode(42); err == nil {
+		t.Errorf("Encode should reject a message that isn't a struct")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	type Rematch struct {
+		Action string
+		Games  int
+	}
+	r := NewRegistry()
+	r.Register(&Rematch{}, "rematch")
+	if got := r.Actions(); !reflect.DeepEqual(got, []string{"rematch"}) {
+		t.Errorf("Actions() = %v", got)
+	}
+	codec := Codec{Registry: r, Strict: true}
+	msg, err := codec.Decode([]byte(`{"Action": "rematch", "Games": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := msg.(*Rematch); !ok || m.Games != 3 {
+		t.Errorf("Decode gave %#v", msg)
+	}
+
+	for _, bad := range []struct {
+		prototype interface{}
+		actions   []string
+	}{
+		{Rematch{}, []string{"rematch"}},         // taken
+		{struct{ Name string }{}, []string{"x"}}, // no Action
+		{"rematch", []string{"y"}},               // not a struct
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%T, %v) should panic", bad.prototype, bad.actions)
+				}
+			}()
+			r.Register(bad.prototype, bad.actions...)
+		}()
+	}
+}
@@ -42,3 +42,17 @@ type GameOverCommand struct {
 	Action, Message string
 	YouWin          bool
 }
+
+// DrawCommand offers a draw, or answers an offer, in either direction
+// between server and client. Its Action is "offerdraw", "acceptdraw" or
+// "declinedraw", and Color is the color of the player sending it. Draw
+// offers are an extension to Pao, for servers that support them.
+type DrawCommand struct {
+	Action, Color string
+}
+
+// ErrorCommand is a command from the server to a client refusing the
+// client's last command, such as a move that is illegal or out of turn.
+type ErrorCommand struct {
+	Action, Message string
+}
@@ -0,0 +1,386 @@
+// Package config describes bots in a JSON file of named presets, so that
+// piece values, flip weighting, tie-breaking and search limits can be
+// changed without rebuilding the server, or even restarting it.
+//
+// A configuration file looks like this:
+//
+//	{
+//	  "default": "hard",
+//	  "presets": {
+//	    "easy": {"bot": "greedy", "flips": "optimistic", "tolerance": 3},
+//	    "hard": {"bot": "alphabeta", "budget": "2s", "workers": 2,
+//	             "weights": {"pieces": {"king": 650}}, "tablebases": "tb"}
+//	  }
+//	}
+//
+// Presets in the file are added to the built-in ones (see Builtin),
+// replacing any of the same name. Relative paths are relative to the file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/bot"
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/logging"
+	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/nn"
+	"github.com/perlmonger42/greedy-bot/opening"
+	"github.com/perlmonger42/greedy-bot/search"
+	"github.com/perlmonger42/greedy-bot/tablebase"
+)
+
+// Config is a set of named presets, one of which is the default.
+type Config struct {
+	Default string             `json:"default"`
+	Presets map[string]*Preset `json:"presets"`
+}
+
+// Preset describes a bot. Options that don't apply to its kind of bot are
+// validation errors, rather than being silently ignored.
+type Preset struct {
+	Bot string `json:"bot"` // "greedy", "alphabeta", or "expectimax"
+	// Weights replaces game.PiecePoints for this bot. Greedy bots use
+	// just the piece values; search bots evaluate with search.Linear.
+	Weights *search.Weights `json:"weights,omitempty"`
+	// Flips is how a flip is valued: "average" (the default),
+	// "pessimistic", or "optimistic". Expectimax always averages.
+	Flips string `json:"flips,omitempty"`
+	// Tolerance and Deterministic control a greedy bot's tie-breaking;
+	// see bot.GreedyBot.
+	Tolerance     int  `json:"tolerance,omitempty"`
+	Deterministic bool `json:"deterministic,omitempty"`
+	// Depth limits a search; Budget limits an alpha-beta search's time per
+	// move. An alpha-beta bot needs at least one of them.
+	Depth   int      `json:"depth,omitempty"`
+	Budget  Duration `json:"budget,omitempty"`
+	Workers int      `json:"workers,omitempty"` // alpha-beta search goroutines
+	// Book is an opening book file, for greedy bots; Tablebases a
+	// directory of tablebases, for alpha-beta bots; and Network a value
+	// network file, which search bots evaluate with instead of Weights.
+	Book       string `json:"book,omitempty"`
+	Tablebases string `json:"tablebases,omitempty"`
+	Network    string `json:"network,omitempty"`
+	// Seed, if set, seeds every game's random choices, so that games
+	// against the same moves are always played the same way. Otherwise
+	// each game gets a seed of its own, which the server logs.
+	Seed *int64 `json:"seed,omitempty"`
+	// Etiquette replaces bot.DefaultEtiquette, which decides when the bot
+	// resigns, and offers or accepts draws. Since a missing resignAfter or
+	// rejectLimit is zero, a preset that gives an etiquette never resigns
+	// unless it says when to.
+	Etiquette *bot.Etiquette `json:"etiquette,omitempty"`
+
+	book   *opening.Book
+	tables *tablebase.Set
+	eval   search.Evaluator
+}
+
+// Duration is a time.Duration written in JSON as a string like "250ms".
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations are strings like \"250ms\"; got %s", data)
+	}
+	var err error
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+// ValidationError lists everything wrong with a configuration file.
+type ValidationError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.File, strings.Join(e.Problems, "; "))
+}
+
+// Builtin returns the presets that are always available: "greedy", the bot
+// this server has always run, which is the default; "easy", a greedy bot
+// that often misses the best move; and "hard", an alpha-beta search, which
+// resigns once it has searched itself 1000 points behind three times in a
+// row (losing a face-up king costs 1400).
+func Builtin() *Config {
+	return &Config{
+		Default: "greedy",
+		Presets: map[string]*Preset{
+			"greedy": {Bot: "greedy"},
+			"easy":   {Bot: "greedy", Flips: "optimistic", Tolerance: 3},
+			"hard": {Bot: "alphabeta", Budget: Duration{2 * time.Second},
+				Workers: 2, Etiquette: &bot.Etiquette{ResignBelow: -1000,
+					ResignAfter: 3, RejectLimit: 3}},
+		},
+	}
+}
+
+// Load reads a configuration file, adding its presets to the built-in
+// ones, and loads the books, tablebases and networks they mention. Any
+// problems are reported together, as a *ValidationError.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file Config
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, &ValidationError{File: path, Problems: []string{err.Error()}}
+	}
+	c := Builtin()
+	if file.Default != "" {
+		c.Default = file.Default
+	}
+	for name, p := range file.Presets {
+		if p == nil {
+			return nil, &ValidationError{File: path,
+				Problems: []string{fmt.Sprintf("preset %q is null", name)}}
+		}
+		c.Presets[name] = p
+	}
+	if problems := c.validate(filepath.Dir(path)); len(problems) > 0 {
+		return nil, &ValidationError{File: path, Problems: problems}
+	}
+	return c, nil
+}
+
+// Names lists the presets, in alphabetical order.
+func (c *Config) Names() []string {
+	names := []string{}
+	for name := range c.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Preset finds a preset by name; the empty name is the default.
+func (c *Config) Preset(name string) (*Preset, bool) {
+	if name == "" {
+		name = c.Default
+	}
+	p, ok := c.Presets[name]
+	return p, ok
+}
+
+// validate checks every preset, loading their files, and returns a
+// description of each problem found. Relative paths are taken relative
+// to dir.
+func (c *Config) validate(dir string) []string {
+	problems := []string{}
+	if _, ok := c.Presets[c.Default]; !ok {
+		problems = append(problems, fmt.Sprintf("the default preset %q doesn't exist", c.Default))
+	}
+	for _, name := range c.Names() {
+		for _, problem := range c.Presets[name].validate(dir) {
+			problems = append(problems, fmt.Sprintf("preset %q: %s", name, problem))
+		}
+	}
+	return problems
+}
+
+var flipPolicies = map[string]search.FlipPolicy{
+	"":            search.FlipAverage,
+	"average":     search.FlipAverage,
+	"pessimistic": search.FlipPessimistic,
+	"optimistic":  search.FlipOptimistic,
+}
+
+func (p *Preset) validate(dir string) []string {
+	problems := []string{}
+	complain := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	only := func(set bool, option string, bots ...string) {
+		if !set {
+			return
+		}
+		for _, b := range bots {
+			if b == p.Bot {
+				return
+			}
+		}
+		complain("%s doesn't apply to a %s bot", option, p.Bot)
+	}
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	switch p.Bot {
+	case "greedy", "alphabeta", "expectimax":
+	case "":
+		complain("no bot given")
+	default:
+		complain("unknown bot %q", p.Bot)
+	}
+	if _, ok := flipPolicies[p.Flips]; !ok {
+		complain("unknown flips %q", p.Flips)
+	}
+	only(p.Flips != "", "flips", "greedy", "alphabeta")
+	only(p.Tolerance != 0, "tolerance", "greedy")
+	only(p.Deterministic, "deterministic", "greedy")
+	only(p.Depth != 0, "depth", "alphabeta", "expectimax")
+	only(p.Budget.Duration != 0, "budget", "alphabeta")
+	only(p.Workers != 0, "workers", "alphabeta")
+	only(p.Book != "", "book", "greedy")
+	only(p.Tablebases != "", "tablebases", "alphabeta")
+	only(p.Network != "", "network", "alphabeta", "expectimax")
+	if p.Tolerance < 0 {
+		complain("tolerance can't be negative")
+	}
+	if p.Depth < 0 || p.Depth > search.MaxPly {
+		complain("depth must be between 0 (no limit) and %d", search.MaxPly)
+	}
+	if p.Budget.Duration < 0 {
+		complain("budget can't be negative")
+	}
+	if p.Workers < 0 {
+		complain("workers can't be negative")
+	}
+	if p.Bot == "alphabeta" && p.Depth == 0 && p.Budget.Duration == 0 {
+		complain("an alphabeta bot needs a depth or a budget")
+	}
+	if p.Bot == "expectimax" && p.Depth == 0 {
+		complain("an expectimax bot needs a depth")
+	}
+	if p.Etiquette != nil && p.Etiquette.ResignAfter < 0 {
+		complain("resignAfter can't be negative")
+	}
+	if p.Etiquette != nil && p.Etiquette.RejectLimit < 0 {
+		complain("rejectLimit can't be negative")
+	}
+	if p.Weights != nil {
+		for kind, v := range p.Weights.Pieces {
+			if !isKind(kind) {
+				complain("unknown kind of piece %q", kind)
+			} else if v < 0 {
+				complain("the value of a %s can't be negative", kind)
+			}
+		}
+		if p.Network != "" {
+			complain("give weights or a network, not both")
+		}
+	}
+
+	if p.Book != "" {
+		book, err := opening.Load(resolve(p.Book))
+		if err != nil {
+			complain("book: %v", err)
+		}
+		p.book = book
+	}
+	if p.Tablebases != "" {
+		path := resolve(p.Tablebases)
+		if info, err := os.Stat(path); err != nil {
+			complain("tablebases: %v", err)
+		} else if !info.IsDir() {
+			complain("tablebases: %s is not a directory", path)
+		}
+		p.tables = tablebase.Open(path)
+	}
+	if p.Network != "" {
+		net, err := nn.Load(resolve(p.Network))
+		if err != nil {
+			complain("network: %v", err)
+		} else {
+			p.eval = nn.NewEvaluator(net)
+		}
+	} else if p.Weights != nil {
+		p.eval = search.NewLinear(p.complete(*p.Weights))
+	}
+	return problems
+}
+
+// complete fills in the piece values missing from w with the defaults.
+func (p *Preset) complete(w search.Weights) search.Weights {
+	pieces := search.DefaultWeights().Pieces
+	for kind, v := range w.Pieces {
+		pieces[kind] = v
+	}
+	w.Pieces = pieces
+	return w
+}
+
+func isKind(kind string) bool {
+	for _, k := range search.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEtiquette returns the preset's etiquette for a new game. An alpha-beta
+// bot's tablebases help it recognize drawn endings.
+func (p *Preset) NewEtiquette() bot.Etiquette {
+	e := bot.DefaultEtiquette()
+	if p.Etiquette != nil {
+		e = *p.Etiquette
+		e.Reset()
+	}
+	e.Tablebases = p.tables
+	return e
+}
+
+// Bot is what NewBot builds; it satisfies pao.Bot.
+type Bot interface {
+	Name() string
+	ChooseMove(*game.State) move.T
+}
+
+// NewBot builds a bot as the preset describes, logging its reasoning to
+// log. Bots hold the state of their game, so each game needs its own; the
+// files the preset mentions were loaded when it was, and are shared.
+func (p *Preset) NewBot(log *logging.Logger) Bot {
+	switch p.Bot {
+	case "alphabeta":
+		workers := p.Workers
+		if workers == 0 {
+			workers = 1
+		}
+		b := bot.NewAlphaBetaBot(p.Budget.Duration, workers)
+		b.Tablebases, b.Log = p.tables, log
+		s := b.Searcher()
+		s.MaxDepth, s.Flips = p.Depth, flipPolicies[p.Flips]
+		if p.eval != nil {
+			s.Eval = p.eval
+		}
+		return b
+	case "expectimax":
+		b := bot.NewExpectimaxBot(p.Depth)
+		b.Log = log
+		if p.eval != nil {
+			b.SetEvaluator(p.eval)
+		}
+		return b
+	}
+	b := bot.NewGreedyBot()
+	b.Book, b.Flips = p.book, flipPolicies[p.Flips]
+	b.Tolerance, b.Deterministic = p.Tolerance, p.Deterministic
+	b.Log = log
+	if p.Weights != nil {
+		b.Values = p.Weights.PieceValues()
+	}
+	return b
+}
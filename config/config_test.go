@@ -0,0 +1,123 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/bot"
+	"github.com/perlmonger42/greedy-bot/search"
+)
+
+func writeConfig(t *testing.T, dir, text string) string {
+	path := filepath.Join(dir, "bots.json")
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeConfig(t, dir, `{
+		"default": "fast",
+		"presets": {
+			"fast": {"bot": "alphabeta", "budget": "50ms", "flips": "pessimistic",
+			         "weights": {"pieces": {"king": 650}}},
+			"easy": {"bot": "greedy", "tolerance": 5, "deterministic": true}
+		}
+	}`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := strings.Join(c.Names(), " "), "easy fast greedy hard"; have != want {
+		t.Errorf("expected presets %s; got %s", want, have)
+	}
+	p, ok := c.Preset("")
+	if !ok || p.Budget.Duration != 50*time.Millisecond {
+		t.Fatalf("expected the default to be the fast preset; got %+v", p)
+	}
+	ab := p.NewBot(nil).(*bot.AlphaBetaBot)
+	if ab.Budget != 50*time.Millisecond || ab.Searcher().Flips != search.FlipPessimistic {
+		t.Errorf("the alphabeta bot wasn't configured: %+v", ab.Searcher())
+	}
+	if _, ok := ab.Searcher().Eval.(*search.Linear); !ok {
+		t.Errorf("expected the weights to be evaluated with search.Linear")
+	}
+	easy, _ := c.Preset("easy")
+	if g := easy.NewBot(nil).(bot.GreedyBot); g.Tolerance != 5 || !g.Deterministic {
+		t.Errorf("the file's easy preset should replace the built-in one; got %+v", g)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeConfig(t, dir, `{
+		"default": "missing",
+		"presets": {
+			"a": {"bot": "greedy", "depth": 3, "flips": "hopeful"},
+			"b": {"bot": "alphabeta", "weights": {"pieces": {"queen": 9}}},
+			"c": {"bot": "expectimax", "depth": 2, "network": "nowhere.json"},
+			"d": {"bot": "alphabeta", "depth": -1, "budget": "1s"}
+		}
+	}`)
+	_, err = Load(path)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error; got %v", err)
+	}
+	for _, want := range []string{
+		`default preset "missing"`,
+		`preset "a": unknown flips "hopeful"`,
+		`preset "a": depth doesn't apply to a greedy bot`,
+		`preset "b": an alphabeta bot needs a depth or a budget`,
+		`preset "b": unknown kind of piece "queen"`,
+		`preset "c": network:`,
+		`preset "d": depth must be between 0 (no limit) and`,
+	} {
+		if !strings.Contains(verr.Error(), want) {
+			t.Errorf("expected the problems to include %q; got %v", want, verr.Problems)
+		}
+	}
+
+	writeConfig(t, dir, `{"presets": {"a": {"bot": "greedy", "tolerence": 3}}}`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "tolerence") {
+		t.Errorf("expected an error for the misspelt option; got %v", err)
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeConfig(t, dir, `{"default": "easy"}`)
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, `{"default": "hard"}`)
+	if err := s.Reload(); err != nil || s.Config().Default != "hard" {
+		t.Errorf("expected the reload to take effect; got %v, %q", err, s.Config().Default)
+	}
+	writeConfig(t, dir, `{"default": `)
+	if err := s.Reload(); err == nil || s.Config().Default != "hard" {
+		t.Errorf("a bad file should leave the old configuration; got %v, %q", err, s.Config().Default)
+	}
+}
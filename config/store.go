@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/logging"
+)
+
+// Store holds the current configuration, and reloads it from its file on
+// request or when the file changes. A file that fails to load leaves the
+// previous configuration in place. Games already running keep the bots
+// they started with; new games get the new configuration.
+type Store struct {
+	path string
+	Log  *logging.Logger // reports Watch's reloads, if set
+
+	mu       sync.RWMutex
+	config   *Config
+	modified time.Time // the file's modification time when last loaded
+}
+
+// NewStore loads the configuration file at path. With no path, the store
+// holds just the built-in presets.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, config: Builtin()}
+	if path == "" {
+		return s, nil
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Config returns the current configuration. It must not be modified.
+func (s *Store) Config() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// Reload rereads the file.
+func (s *Store) Reload() error {
+	if s.path == "" {
+		return nil
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	c, err := Load(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.config, s.modified = c, info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch checks the file every interval, and reloads it when its
+// modification time changes, until ctx is cancelled. Reload errors are
+// reported and otherwise ignored, so that a half-edited file does no harm.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if s.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(s.path)
+		s.mu.RLock()
+		changed := err == nil && !info.ModTime().Equal(s.modified)
+		s.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := s.Reload(); err != nil {
+			s.Log.Warn("keeping the old configuration", "err", err)
+			s.mu.Lock()
+			s.modified = info.ModTime() // don't complain again until it changes
+			s.mu.Unlock()
+		} else {
+			s.Log.Info("reloaded configuration", "path", s.path)
+		}
+	}
+}
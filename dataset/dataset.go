@@ -0,0 +1,91 @@
+// Package dataset reads and writes positions for learning evaluations, one
+// JSON object per line.
+package dataset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// Position is a game state, in Pao's notation, along with how the game
+// turned out for the side to move. Positions recorded from self-play also
+// say where they came from and what was played.
+type Position struct {
+	Board  [][]string     `json:"board"`
+	Dead   []string       `json:"dead"`
+	Down   map[string]int `json:"down"` // redundant with Board and Dead, but handy
+	ToMove string         `json:"toMove"`
+	// Result is 1 if the side to move went on to win, 0 if it lost, and
+	// one half for a draw.
+	Result float64 `json:"result"`
+
+	Game  int    `json:"game"`            // which game of the dataset it is from
+	Ply   int    `json:"ply"`             // its index among the game's positions
+	Plies int    `json:"plies"`           // how many positions the game has
+	Move  string `json:"move,omitempty"`  // the move played, as a Pao move argument
+	Score *int   `json:"score,omitempty"` // the mover's search score, if it had one
+}
+
+// NewPosition describes gs, which must have a side to move.
+func NewPosition(gs *game.State, result float64) Position {
+	p := Position{
+		Board:  make([][]string, 4),
+		Dead:   []string{},
+		Down:   map[string]int{},
+		ToMove: gs.Us.Color(),
+		Result: result,
+	}
+	for r, row := range gs.Board {
+		for _, piece := range row {
+			p.Board[r] = append(p.Board[r], piece.Descriptor())
+		}
+	}
+	for _, piece := range gs.Dead {
+		p.Dead = append(p.Dead, piece.Descriptor())
+	}
+	for piece, n := range gs.Down {
+		p.Down[piece.Descriptor()] = n
+	}
+	return p
+}
+
+// State rebuilds the game state the position describes.
+func (p *Position) State() game.State {
+	return game.NewState(p.ToMove, p.Board, p.Dead)
+}
+
+// Write writes one position as a line of JSON.
+func Write(w io.Writer, p *Position) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Read reads every position from r. Blank lines are skipped; anything else
+// that isn't a position is an error.
+func Read(r io.Reader) ([]Position, error) {
+	positions := []Position{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var p Position
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if len(p.Board) != 4 {
+			return nil, fmt.Errorf("line %d: board should have 4 rows", line)
+		}
+		positions = append(positions, p)
+	}
+	return positions, scanner.Err()
+}
@@ -0,0 +1,48 @@
+package dataset
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+func TestRoundTrip(t *testing.T) {
+	gs := game.NewState("Black", [][]string{
+		{"?", ".", "?", ".", "?", "E", ".", "?"},
+		{".", "?", "p", ".", "H", "k", "?", "e"},
+		{"g", "G", "C", "Q", ".", "p", ".", "q"},
+		{"h", "p", ".", "?", "c", ".", "P", "?"},
+	}, []string{"P", "P", "h", "c"})
+	p := NewPosition(&gs, 0.5)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, &p); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(&buf, &p); err != nil {
+		t.Fatal(err)
+	}
+	positions, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("expected 2 positions; got %d", len(positions))
+	}
+	back := positions[1].State()
+	if back.Board != gs.Board || back.Score != gs.Score || back.Us != gs.Us {
+		t.Errorf("the position didn't survive the round trip: %+v", positions[1])
+	}
+	if positions[1].Down["P"] != gs.Down[game.BlackPawn] || positions[1].Result != 0.5 {
+		t.Errorf("expected %d black pawns down and a draw; got %+v", gs.Down[game.BlackPawn], positions[1])
+	}
+}
+
+func TestReadReportsBadLines(t *testing.T) {
+	_, err := Read(strings.NewReader("{\"board\": []}\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected an error on line 1; got %v", err)
+	}
+}
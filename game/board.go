@@ -1,5 +1,10 @@
 package game
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Board represents the content of all the squares of a Ban Chi game
 type Board [4][8]Piece
 
@@ -15,6 +20,29 @@ func NewBoard(boardAsStrings [][]string) Board {
 	return board
 }
 
+// ParseBoard is NewBoard for descriptions that might be malformed. It
+// insists on four rows of eight squares, and returns an error rather than
+// panicking.
+func ParseBoard(boardAsStrings [][]string) (Board, error) {
+	board := Board{}
+	if len(boardAsStrings) != len(board) {
+		return board, fmt.Errorf("board has %d rows, not %d", len(boardAsStrings), len(board))
+	}
+	for r, row := range boardAsStrings {
+		if len(row) != len(board[r]) {
+			return board, fmt.Errorf("board row %d has %d squares, not %d", r+1, len(row), len(board[r]))
+		}
+		for c, str := range row {
+			piece, err := ParsePiece(str)
+			if err != nil {
+				return board, fmt.Errorf("square %c%d: %w", 'A'+c, r+1, err)
+			}
+			board[r][c] = piece
+		}
+	}
+	return board, nil
+}
+
 func (board *Board) At(row, col int) Piece {
 	return board[row][col]
 }
@@ -26,3 +54,18 @@ func (board *Board) Each(visit func(Piece)) {
 		}
 	}
 }
+
+// String describes the board compactly, a row at a time from row 1, in
+// Pao's notation: "p.g....?/......../E......./.......?".
+func (board *Board) String() string {
+	var b strings.Builder
+	for r, row := range board {
+		if r > 0 {
+			b.WriteByte('/')
+		}
+		for _, p := range row {
+			b.WriteString(p.Descriptor())
+		}
+	}
+	return b.String()
+}
@@ -37,3 +37,15 @@ func Test_NewBoard_and_At(t *testing.T) {
 		}
 	}
 }
+
+func TestBoardString(t *testing.T) {
+	board := NewBoard([][]string{
+		{"?", ".", "?", ".", "?", "E", ".", "?"},
+		{"?", "?", "p", "?", "H", "k", "?", "e"},
+		{"g", "G", "C", "Q", "?", "p", ".", "q"},
+		{"h", "p", "?", "?", "c", "p", "P", "?"},
+	})
+	if have, want := board.String(), "?.?.?E.?/??p?Hk?e/gGCQ?p.q/hp??cpP?"; have != want {
+		t.Errorf("expected the board to be described as %s; got %s", want, have)
+	}
+}
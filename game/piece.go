@@ -38,12 +38,30 @@ const (
 	BlackKing
 )
 
-func NewPiece(paoDescriptor string) Piece {
+// PieceError reports a Pao-style descriptor that describes no piece.
+type PieceError struct {
+	Descriptor string
+}
+
+func (e *PieceError) Error() string {
+	return fmt.Sprintf("unknown piece descriptor: %q", e.Descriptor)
+}
+
+// ParsePiece is NewPiece for descriptors that might be malformed: it
+// returns a *PieceError rather than panicking.
+func ParsePiece(paoDescriptor string) (Piece, error) {
 	if piece, ok := paoStringToPiece[paoDescriptor]; ok {
-		return piece
-	} else {
-		panic(fmt.Sprintf("unknown piece descriptor: %q", paoDescriptor))
+		return piece, nil
+	}
+	return None, &PieceError{paoDescriptor}
+}
+
+func NewPiece(paoDescriptor string) Piece {
+	piece, err := ParsePiece(paoDescriptor)
+	if err != nil {
+		panic(err.Error())
 	}
+	return piece
 }
 
 var paoStringToPiece map[string]Piece = map[string]Piece{
@@ -66,6 +84,17 @@ var paoStringToPiece map[string]Piece = map[string]Piece{
 	" ": None,
 }
 
+// Descriptor is the inverse of NewPiece: it returns the Pao-style
+// descriptor of p ("q", "K", "?", ".", etc).
+func (p Piece) Descriptor() string {
+	for str, piece := range paoStringToPiece {
+		if piece == p && str != " " {
+			return str
+		}
+	}
+	panic(fmt.Sprintf("piece has no descriptor: %d", p))
+}
+
 func (p Piece) AsSingletonSet() SetOfPieces {
 	return SetOfPieces(p)
 }
@@ -32,6 +32,16 @@ func TestNewPiece(t *testing.T) {
 	}
 }
 
+func TestDescriptor(t *testing.T) {
+	for _, name := range []string{".", "?", "q", "p", "h", "c", "e", "g", "k",
+		"Q", "P", "H", "C", "E", "G", "K"} {
+		if have := NewPiece(name).Descriptor(); have != name {
+			t.Errorf("%s should describe itself as %q; got %q\n",
+				NewPiece(name), name, have)
+		}
+	}
+}
+
 func TestNewPieceFailure(t *testing.T) {
 	defer func() {
 		expected := "unknown piece descriptor: \"x\""
@@ -44,6 +54,16 @@ func TestNewPieceFailure(t *testing.T) {
 	_ = NewPiece("x")
 }
 
+func TestParsePiece(t *testing.T) {
+	if piece, err := ParsePiece("H"); piece != BlackHorse || err != nil {
+		t.Errorf("ParsePiece(\"H\") = %v, %v; want BlackHorse", piece, err)
+	}
+	_, err := ParsePiece("x")
+	if perr, ok := err.(*PieceError); !ok || perr.Descriptor != "x" {
+		t.Errorf("ParsePiece(\"x\") error = %#v; want a *PieceError for \"x\"", err)
+	}
+}
+
 func TestCanTakeIfAdjacent(t *testing.T) {
 	// expected[i][j] tells whether
 	// team1.QPHCEGK[i] can take team2.QPHCEGK[j]
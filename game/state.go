@@ -1,6 +1,9 @@
 package game
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 // State represents the current state of a game of Ban Chi.
 type State struct {
@@ -31,6 +34,74 @@ func NewState(toMove string, boardStrs [][]string, deadStrs []string) State {
 	return State{Board: board, Dead: dead, Down: down, Score: score, Us: us, Them: them}
 }
 
+// ParseState is NewState for descriptions that might be malformed, such
+// as those from a Pao server: it returns an error rather than panicking.
+func ParseState(toMove string, boardStrs [][]string, deadStrs []string) (State, error) {
+	if _, err := ParseBoard(boardStrs); err != nil {
+		return State{}, err
+	}
+	for _, str := range deadStrs {
+		if piece, err := ParsePiece(str); err != nil {
+			return State{}, fmt.Errorf("dead pieces: %w", err)
+		} else if piece == None || piece == FaceDown {
+			return State{}, fmt.Errorf("dead pieces: %q is not a piece", str)
+		}
+	}
+	return NewState(toMove, boardStrs, deadStrs), nil
+}
+
+// Clone returns a copy of gs that can be modified without disturbing gs.
+func (gs *State) Clone() State {
+	dead := make([]Piece, len(gs.Dead))
+	copy(dead, gs.Dead)
+	down := make(map[Piece]int, len(gs.Down))
+	for p, n := range gs.Down {
+		down[p] = n
+	}
+	return State{
+		Board: gs.Board, Dead: dead, Down: down, Score: gs.Score,
+		Us: gs.Us, Them: gs.Them,
+	}
+}
+
+// Reveal turns up the face-down piece at row,col, which turned out to be p.
+// On the first move of the game, revealing a piece also decides the teams:
+// whoever flips it is the color of the piece revealed.
+func (gs *State) Reveal(row, col int, p Piece) {
+	gs.Board[row][col] = p
+	if gs.Down[p] <= 1 {
+		delete(gs.Down, p)
+	} else {
+		gs.Down[p] -= 1
+	}
+	gs.Score += PiecePoints[p]
+	if gs.Us == nil {
+		gs.Us = TeamOf(p)
+		gs.Them = gs.Us.Other()
+	}
+}
+
+// Relocate moves the piece at row,col to the empty square at row2,col2.
+func (gs *State) Relocate(row, col, row2, col2 int) {
+	gs.Board[row2][col2] = gs.Board[row][col]
+	gs.Board[row][col] = None
+}
+
+// Capture moves the piece at row,col onto row2,col2, killing the piece
+// that was there. The victim's points leave the score twice: once for
+// leaving the board, and once for joining the dead.
+func (gs *State) Capture(row, col, row2, col2 int) {
+	victim := gs.Board[row2][col2]
+	gs.Dead = append(gs.Dead, victim)
+	gs.Score -= 2 * PiecePoints[victim]
+	gs.Relocate(row, col, row2, col2)
+}
+
+// PassTurn makes it the other team's turn to play.
+func (gs *State) PassTurn() {
+	gs.Us, gs.Them = gs.Them, gs.Us
+}
+
 func deadList(deadAsStrings []string) []Piece {
 	dead := []Piece{}
 	for _, str := range deadAsStrings {
@@ -171,3 +171,30 @@ func requireAllEmpty(t *testing.T, gs State) {
 		}
 	}
 }
+
+func TestParseState(t *testing.T) {
+	row := []string{"?", "?", "?", "?", "?", "?", "?", "?"}
+	good := [][]string{row, row, row, {"?", "?", "?", "?", "?", "?", "q", "."}}
+	if gs, err := ParseState("Red", good, []string{"P"}); err != nil {
+		t.Errorf("ParseState of a good state: %v", err)
+	} else if gs.Board[3][6] != RedCannon || len(gs.Dead) != 1 {
+		t.Errorf("ParseState parsed %v with dead %v", gs.Board.String(), gs.Dead)
+	}
+	for _, test := range []struct {
+		name  string
+		board [][]string
+		dead  []string
+		want  string
+	}{
+		{"too few rows", good[:3], nil, "board has 3 rows, not 4"},
+		{"long row", [][]string{row, row, row, append(row, "?")}, nil, "board row 4 has 9 squares, not 8"},
+		{"bad piece", [][]string{row, {"?", "x", "?", "?", "?", "?", "?", "?"}, row, row}, nil,
+			`square B2: unknown piece descriptor: "x"`},
+		{"bad dead piece", good, []string{"z"}, `dead pieces: unknown piece descriptor: "z"`},
+		{"empty dead piece", good, []string{"."}, `dead pieces: "." is not a piece`},
+	} {
+		if _, err := ParseState("Red", test.board, test.dead); err == nil || err.Error() != test.want {
+			t.Errorf("%s: ParseState error = %v; want %q", test.name, err, test.want)
+		}
+	}
+}
@@ -83,3 +83,29 @@ var Teams [2]*Team = [2]*Team{&RedTeam, &BlackTeam}
 func (team *Team) Contains(p Piece) bool {
 	return team.Set.Contains(p)
 }
+
+// TeamOf returns the team that owns piece p, or nil if p is None or FaceDown.
+func TeamOf(p Piece) *Team {
+	if RedTeam.Contains(p) {
+		return &RedTeam
+	} else if BlackTeam.Contains(p) {
+		return &BlackTeam
+	}
+	return nil
+}
+
+// Other returns the opposing team.
+func (team *Team) Other() *Team {
+	if team == &RedTeam {
+		return &BlackTeam
+	}
+	return &RedTeam
+}
+
+// Color returns "Red" or "Black", as used in Pao's color commands.
+func (team *Team) Color() string {
+	if team.K == BlackKing {
+		return "Black"
+	}
+	return "Red"
+}
@@ -0,0 +1,253 @@
+// Package logging writes leveled, structured log lines in logfmt:
+//
+//	time=2020-06-21T10:04:05.123Z level=info sys=pao session=7 msg="game over" winner=Red
+//
+// Loggers are cheap to derive from one another: With adds fields to every
+// line, and Sub names the subsystem, whose level can be set on its own. A
+// nil *Logger discards everything, so a bot nobody gave a logger is quiet.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+	Off
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error", "off"}
+
+func (l Level) String() string {
+	if l < Debug || l > Off {
+		return strconv.Itoa(int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel accepts the names String returns, in any case.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return Info, fmt.Errorf("unknown log level %q", s)
+}
+
+// DefaultLevel is the level of subsystems that haven't been given one:
+// quiet enough for production, logging sessions and games but not moves.
+const DefaultLevel = Info
+
+// output is what a family of loggers share: where lines go, and the
+// levels of the subsystems.
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	levels map[string]Level // by subsystem; "" is everything else
+	now    func() time.Time
+}
+
+// Logger writes lines for one subsystem, with some fields of its own.
+type Logger struct {
+	out    *output
+	sys    string
+	fields []interface{} // alternating keys and values
+}
+
+// New returns a logger that writes to w, at DefaultLevel.
+func New(w io.Writer) *Logger {
+	return &Logger{out: &output{w: w, levels: map[string]Level{"": DefaultLevel}, now: time.Now}}
+}
+
+// Default writes to stderr. The server and commands log through it.
+var Default = New(os.Stderr)
+
+// With returns a logger that adds the given keys and values to every line.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	if l == nil {
+		return nil
+	}
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(append(fields, l.fields...), kv...)
+	return &Logger{out: l.out, sys: l.sys, fields: fields}
+}
+
+// Sub returns a logger for the named subsystem, keeping l's fields.
+func (l *Logger) Sub(sys string) *Logger {
+	if l == nil {
+		return nil
+	}
+	return &Logger{out: l.out, sys: sys, fields: l.fields}
+}
+
+// SetLevel sets the level of a subsystem, or with sys "", of every
+// subsystem not given its own. It affects every logger sharing l's output.
+func (l *Logger) SetLevel(sys string, level Level) {
+	if l == nil {
+		return
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.levels[sys] = level
+}
+
+// Configure sets levels from a description like "warn,bot=debug,pao=info":
+// a bare level is the default, and the rest are per subsystem. Nothing is
+// changed if the description has a mistake in it.
+func (l *Logger) Configure(spec string) error {
+	levels := map[string]Level{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		sys, name := "", part
+		if i := strings.Index(part, "="); i >= 0 {
+			sys, name = part[:i], part[i+1:]
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		levels[sys] = level
+	}
+	for sys, level := range levels {
+		l.SetLevel(sys, level)
+	}
+	return nil
+}
+
+// Levels returns the levels that have been set, by subsystem, with ""
+// for the default.
+func (l *Logger) Levels() map[string]Level {
+	levels := map[string]Level{}
+	if l == nil {
+		return levels
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	for sys, level := range l.out.levels {
+		levels[sys] = level
+	}
+	return levels
+}
+
+// Enabled says whether lines at the given level would be written, for
+// callers that have expensive fields to compute.
+func (l *Logger) Enabled(level Level) bool {
+	if l == nil {
+		return false
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	return l.enabled(level)
+}
+
+func (l *Logger) enabled(level Level) bool {
+	min, ok := l.out.levels[l.sys]
+	if !ok {
+		min = l.out.levels[""]
+	}
+	return level >= min && level < Off
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(Debug, msg, kv) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.log(Info, msg, kv) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.log(Warn, msg, kv) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(Error, msg, kv) }
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if l == nil {
+		return
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	if !l.enabled(level) {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(l.out.now().UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteString(" level=")
+	b.WriteString(level.String())
+	if l.sys != "" {
+		b.WriteString(" sys=")
+		writeValue(&b, l.sys)
+	}
+	writePairs(&b, l.fields)
+	b.WriteString(" msg=")
+	writeValue(&b, msg)
+	writePairs(&b, kv)
+	b.WriteByte('\n')
+	io.WriteString(l.out.w, b.String())
+}
+
+func writePairs(b *strings.Builder, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		b.WriteByte(' ')
+		b.WriteString(key(kv[i]))
+		b.WriteByte('=')
+		if i+1 < len(kv) {
+			writeValue(b, kv[i+1])
+		} else {
+			b.WriteString(`""`) // a key without a value
+		}
+	}
+}
+
+// key makes anything usable as a logfmt key.
+func key(k interface{}) string {
+	s := fmt.Sprint(k)
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// writeValue writes v, quoted if it needs to be.
+func writeValue(b *strings.Builder, v interface{}) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if needsQuotes(s) {
+		s = strconv.Quote(s)
+	}
+	b.WriteString(s)
+}
+
+func needsQuotes(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r == ' ' || r == '=' || r == '"' || r == '\\' || !strconv.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
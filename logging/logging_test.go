@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger() (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := New(&buf)
+	l.out.now = func() time.Time { return time.Date(2020, 6, 21, 10, 4, 5, 123e6, time.UTC) }
+	return l, &buf
+}
+
+func TestFormat(t *testing.T) {
+	l, buf := newTestLogger()
+	l.Sub("pao").With("session", 7).Info("game over", "winner", "Red",
+		"err", errors.New(`no "luck"`), "empty", "", "dangling")
+	want := `time=2020-06-21T10:04:05.123Z level=info sys=pao session=7 msg="game over" ` +
+		`winner=Red err="no \"luck\"" empty="" dangling=""` + "\n"
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+func TestLevels(t *testing.T) {
+	l, buf := newTestLogger()
+	pao, bot := l.Sub("pao"), l.Sub("bot")
+	bot.Debug("hidden")
+	pao.Info("shown")
+	if err := l.Configure("warn, bot=debug"); err != nil {
+		t.Fatal(err)
+	}
+	bot.Debug("shown")
+	pao.Info("hidden")
+	pao.Warn("shown")
+	if err := l.Configure("bot=loud"); err == nil {
+		t.Errorf("expected an error for an unknown level")
+	}
+	if strings.Contains(buf.String(), "hidden") || strings.Count(buf.String(), "shown") != 3 {
+		t.Errorf("expected only the lines at enabled levels; got\n%s", buf.String())
+	}
+	if levels := l.Levels(); levels[""] != Warn || levels["bot"] != Debug {
+		t.Errorf("the levels weren't recorded: %v", levels)
+	}
+}
+
+func TestNilLogger(t *testing.T) {
+	var l *Logger
+	l.With("session", 1).Sub("bot").Error("nobody hears this")
+	if l.Enabled(Error) {
+		t.Errorf("a nil logger shouldn't be enabled")
+	}
+}
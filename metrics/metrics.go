@@ -0,0 +1,240 @@
+// Package metrics keeps counters, gauges and histograms, and serves them
+// over HTTP in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds a set of metrics, in the order they were made.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// Default is where the server's metrics live.
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.Write(w)
+}
+
+// family is what every kind of metric has: a name, a description, and
+// the names of its labels, whose values pick out one of its series.
+type family struct {
+	name, help, kind string
+	labels           []string
+	mu               sync.Mutex
+}
+
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v; got values %v", f.name, f.labels, values))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (f *family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+}
+
+// labelText formats label pairs as {a="x",b="y"}, adding any extra pair.
+func (f *family) labelText(key string, extra ...string) string {
+	pairs := []string{}
+	if len(f.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			pairs = append(pairs, f.labels[i]+"="+quote(v))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+quote(extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func quote(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
+
+func number(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys lists a series map's keys in order, so output is stable.
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Value is a counter or a gauge: a number per combination of labels.
+type Value struct {
+	family
+	values map[string]float64
+}
+
+// NewCounter makes a counter, which only goes up.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Value {
+	return r.newValue(name, help, "counter", labels)
+}
+
+// NewGauge makes a gauge, which goes up and down.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Value {
+	return r.newValue(name, help, "gauge", labels)
+}
+
+func (r *Registry) newValue(name, help, kind string, labels []string) *Value {
+	v := &Value{family: family{name: name, help: help, kind: kind, labels: labels},
+		values: map[string]float64{}}
+	if len(labels) == 0 {
+		v.values[""] = 0 // unlabeled metrics are reported from the start
+	}
+	r.add(v)
+	return v
+}
+
+// Add adds delta to the series with the given label values.
+func (v *Value) Add(delta float64, labelValues ...string) {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[key] += delta
+}
+
+func (v *Value) Inc(labelValues ...string) { v.Add(1, labelValues...) }
+func (v *Value) Dec(labelValues ...string) { v.Add(-1, labelValues...) }
+
+// Set sets a gauge's series with the given label values.
+func (v *Value) Set(x float64, labelValues ...string) {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[key] = x
+}
+
+// Get returns the series with the given label values.
+func (v *Value) Get(labelValues ...string) float64 {
+	key := v.key(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.values[key]
+}
+
+func (v *Value) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.header(w)
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labelText(key), number(v.values[key]))
+	}
+}
+
+// Histogram counts observations in buckets, per combination of labels.
+type Histogram struct {
+	family
+	bounds []float64 // upper bounds of the buckets, ascending
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// LatencyBuckets suit durations in seconds, from a millisecond to a minute.
+var LatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// NewHistogram makes a histogram with buckets bounded by bounds.
+func (r *Registry) NewHistogram(name, help string, bounds []float64, labels ...string) *Histogram {
+	h := &Histogram{family: family{name: name, help: help, kind: "histogram", labels: labels},
+		bounds: bounds, series: map[string]*histogramSeries{}}
+	r.add(h)
+	return h
+}
+
+// Observe records x in the series with the given label values.
+func (h *Histogram) Observe(x float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.bounds)+1)}
+		h.series[key] = s
+	}
+	i := sort.SearchFloat64s(h.bounds, x) // the first bound >= x
+	s.counts[i]++
+	s.sum += x
+	s.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		cumulative := uint64(0)
+		for i, n := range s.counts {
+			cumulative += n
+			le := math.Inf(1)
+			if i < len(h.bounds) {
+				le = h.bounds[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelText(key, "le", number(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelText(key), number(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelText(key), s.count)
+	}
+}
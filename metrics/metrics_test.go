@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	r := NewRegistry()
+	sessions := r.NewGauge("sessions", "Sessions in progress.")
+	games := r.NewCounter("games_total", "Games played, by result.", "result")
+	moves := r.NewHistogram("move_seconds", "Time per move.", []float64{0.1, 1}, "bot")
+	sessions.Inc()
+	sessions.Inc()
+	sessions.Dec()
+	games.Inc("win")
+	games.Add(2, `lo"ss`)
+	moves.Observe(0.05, "Greedy")
+	moves.Observe(0.5, "Greedy")
+	moves.Observe(3, "Greedy")
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	want := `# HELP sessions Sessions in progress.
+# TYPE sessions gauge
+sessions 1
+# HELP games_total Games played, by result.
+# TYPE games_total counter
+games_total{result="lo\"ss"} 2
+games_total{result="win"} 1
+# HELP move_seconds Time per move.
+# TYPE move_seconds histogram
+move_seconds_bucket{bot="Greedy",le="0.1"} 1
+move_seconds_bucket{bot="Greedy",le="1"} 2
+move_seconds_bucket{bot="Greedy",le="+Inf"} 3
+move_seconds_sum{bot="Greedy"} 3.55
+move_seconds_count{bot="Greedy"} 3
+`
+	if buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") || rec.Body.String() != want {
+		t.Errorf("the handler should serve the same text")
+	}
+}
+
+func TestWrongLabels(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for a missing label value")
+		}
+	}()
+	NewRegistry().NewCounter("games_total", "Games.", "result").Inc()
+}
@@ -27,10 +27,35 @@ type Location struct {
 	row, col int
 }
 
+func NewLocation(row, col int) Location {
+	return Location{row, col}
+}
+
+func (loc Location) Row() int {
+	return loc.row
+}
+
+func (loc Location) Col() int {
+	return loc.col
+}
+
 func (loc Location) String() string {
 	return string(rune(loc.col+'A')) + strconv.Itoa(loc.row+1)
 }
 
+// ParseLocation is the inverse of String: it accepts squares like "B3",
+// in either case.
+func ParseLocation(s string) (Location, error) {
+	if len(s) != 2 {
+		return Location{}, fmt.Errorf("bad square %q", s)
+	}
+	col, row := int(s[0]|0x20)-'a', int(s[1])-'1'
+	if col < 0 || col > 7 || row < 0 || row > 3 {
+		return Location{}, fmt.Errorf("bad square %q", s)
+	}
+	return Location{row, col}, nil
+}
+
 type T struct {
 	action Action
 	at     Location   // not used for Quit
@@ -110,6 +135,62 @@ func (m T) Killed() game.Piece {
 	return m.killed
 }
 
+func (m T) Actor() game.Piece {
+	return m.actor
+}
+
+// From returns the square a move starts from (or the square flipped).
+func (m T) From() Location {
+	return m.at
+}
+
+// To returns the destination square of a Move or Take.
+func (m T) To() Location {
+	return m.to
+}
+
+// Apply returns the state that results from playing m in gs. Flips can't
+// be applied this way, because the result depends on which piece turns up;
+// use ApplyFlip for those.
+func (m T) Apply(gs *game.State) game.State {
+	next := gs.Clone()
+	switch m.action {
+	case Move:
+		next.Relocate(m.at.row, m.at.col, m.to.row, m.to.col)
+	case Take:
+		next.Capture(m.at.row, m.at.col, m.to.row, m.to.col)
+	case Flip:
+		panic(fmt.Sprintf("can't apply %s without knowing the piece revealed", m.String()))
+	case Quit:
+		return next
+	}
+	next.PassTurn()
+	return next
+}
+
+// ApplyFlip returns the state that results from playing the flip m in gs,
+// supposing that the piece turned up is p.
+func (m T) ApplyFlip(gs *game.State, p game.Piece) game.State {
+	if m.action != Flip {
+		return m.Apply(gs)
+	}
+	next := gs.Clone()
+	next.Reveal(m.at.row, m.at.col, p)
+	next.PassTurn()
+	return next
+}
+
+// Captures returns just the Take moves from LegalMoves.
+func Captures(team, them *game.Team, board game.Board) []T {
+	takes := []T{}
+	for _, m := range LegalMoves(team, them, board) {
+		if m.action == Take {
+			takes = append(takes, m)
+		}
+	}
+	return takes
+}
+
 type moveFinder struct {
 	team  *game.Team // "us"
 	them  *game.Team // the other team
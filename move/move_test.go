@@ -1,6 +1,7 @@
 package move
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/perlmonger42/greedy-bot/game"
@@ -27,3 +28,17 @@ func TestNoMovesPossible(t *testing.T) {
 		t.Errorf("expected %s; got %s", want_m2, have_m2)
 	}
 }
+
+func TestParseLocation(t *testing.T) {
+	for _, s := range []string{"A1", "H4", "c2"} {
+		loc, err := ParseLocation(s)
+		if err != nil || loc.String() != strings.ToUpper(s) {
+			t.Errorf("%s: got %v, %v", s, loc, err)
+		}
+	}
+	for _, s := range []string{"", "A5", "I1", "A0", "B12"} {
+		if _, err := ParseLocation(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package nn
+
+import (
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// Scale is the evaluation, in the searches' units, of a position the
+// network is certain the side to move will win; it is roughly the value of
+// a whole army in game.PiecePoints.
+const Scale = 1000
+
+// Evaluator scores positions for the searches with a network's predictions,
+// mapped linearly from [0, 1] to [-Scale, Scale]. It is safe to share among
+// goroutines, as long as nobody is training the network at the same time.
+type Evaluator struct {
+	Net *Network
+}
+
+func NewEvaluator(net *Network) *Evaluator {
+	return &Evaluator{Net: net}
+}
+
+func (e *Evaluator) Evaluate(gs *game.State) int {
+	if gs.Us == nil {
+		return 0
+	}
+	return int((2*e.Net.Predict(gs) - 1) * Scale)
+}
+
+func (e *Evaluator) Bounds() (lo, hi int) {
+	return -Scale, Scale
+}
@@ -0,0 +1,63 @@
+// Package nn is a small fully-connected value network: it learns to predict
+// how a game will turn out from a position, and serves as an evaluator for
+// the searches.
+package nn
+
+import (
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// The network's inputs describe a position from the side to move's point of
+// view. Each square has one input for each kind of piece of ours, one for
+// each of theirs, and one for a face-down piece; it is 1 for whatever is on
+// the square. Then come the face-down pieces, ours and theirs, as fractions
+// of how many of each kind a side starts with, and finally an input that is
+// 1 if the side to move is red.
+const (
+	squareInputs = 2*kinds + 1
+	downInputs   = squareInputs * 32
+	redInput     = downInputs + 2*kinds
+	NumInputs    = redInput + 1
+)
+
+const kinds = len(game.RedTeam.QPHCEGK)
+
+// starting is how many of each kind of piece, in QPHCEGK order, a side has.
+var starting = [kinds]float64{2, 5, 2, 2, 2, 2, 1}
+
+// Inputs computes the network's inputs for gs, which must have a side to
+// move.
+func Inputs(gs *game.State) []float64 {
+	x := make([]float64, NumInputs)
+	for r, row := range gs.Board {
+		for c, p := range row {
+			base := (r*8 + c) * squareInputs
+			if p == game.FaceDown {
+				x[base+2*kinds] = 1
+			} else if i := kindOf(gs.Us, p); i >= 0 {
+				x[base+i] = 1
+			} else if i := kindOf(gs.Them, p); i >= 0 {
+				x[base+kinds+i] = 1
+			}
+		}
+	}
+	for i := 0; i < kinds; i++ {
+		x[downInputs+i] = float64(gs.Down[gs.Us.QPHCEGK[i]]) / starting[i]
+		x[downInputs+kinds+i] = float64(gs.Down[gs.Them.QPHCEGK[i]]) / starting[i]
+	}
+	if gs.Us == &game.RedTeam {
+		x[redInput] = 1
+	}
+	return x
+}
+
+// kindOf returns the index of p among the team's pieces, or -1 if it isn't
+// one of them.
+func kindOf(team *game.Team, p game.Piece) int {
+	for i, q := range team.QPHCEGK {
+		if q == p {
+			return i
+		}
+	}
+	return -1
+}
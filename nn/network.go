@@ -0,0 +1,120 @@
+package nn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// Network is a fully-connected network with ReLU hidden layers and a single
+// sigmoid output: the predicted result for the side to move, 1 for a win,
+// 0 for a loss.
+type Network struct {
+	// Sizes lists the number of units in each layer, starting with the
+	// inputs and ending with the output.
+	Sizes []int `json:"sizes"`
+	// Weights[l] connects layer l to layer l+1; the weight from input
+	// unit i to output unit j is Weights[l][j*Sizes[l]+i].
+	Weights [][]float64 `json:"weights"`
+	Biases  [][]float64 `json:"biases"` // Biases[l] belongs to layer l+1
+}
+
+// NewNetwork builds a network with hidden layers of the given sizes,
+// initialized with small random weights drawn from r.
+func NewNetwork(r *rand.Rand, hidden ...int) *Network {
+	sizes := append(append([]int{NumInputs}, hidden...), 1)
+	net := &Network{Sizes: sizes}
+	for l := 0; l+1 < len(sizes); l++ {
+		in, out := sizes[l], sizes[l+1]
+		w := make([]float64, in*out)
+		scale := math.Sqrt(2 / float64(in)) // He initialization, for ReLU
+		for i := range w {
+			w[i] = r.NormFloat64() * scale
+		}
+		net.Weights = append(net.Weights, w)
+		net.Biases = append(net.Biases, make([]float64, out))
+	}
+	return net
+}
+
+// Predict is the network's prediction of the result for the side to move.
+func (net *Network) Predict(gs *game.State) float64 {
+	acts := net.forward(Inputs(gs))
+	return acts[len(acts)-1][0]
+}
+
+// forward computes the activations of every layer, inputs included.
+func (net *Network) forward(x []float64) [][]float64 {
+	acts := [][]float64{x}
+	last := len(net.Weights) - 1
+	for l, w := range net.Weights {
+		in, out := acts[l], append([]float64(nil), net.Biases[l]...)
+		n := len(in)
+		for i, a := range in {
+			if a == 0 { // most inputs are, and half the hidden units
+				continue
+			}
+			for j := range out {
+				out[j] += w[j*n+i] * a
+			}
+		}
+		for j, z := range out {
+			if l == last {
+				out[j] = 1 / (1 + math.Exp(-z))
+			} else if z < 0 {
+				out[j] = 0
+			}
+		}
+		acts = append(acts, out)
+	}
+	return acts
+}
+
+// Load reads a network written by Save.
+func Load(path string) (*Network, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	net := &Network{}
+	if err := json.Unmarshal(data, net); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := net.check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return net, nil
+}
+
+// Save writes the network to path as JSON.
+func (net *Network) Save(path string) error {
+	data, err := json.Marshal(net)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// check makes sure the network's shape is consistent, and that it takes
+// the inputs this version of the package computes.
+func (net *Network) check() error {
+	n := len(net.Sizes)
+	if n < 2 || net.Sizes[0] != NumInputs || net.Sizes[n-1] != 1 {
+		return fmt.Errorf("network must have %d inputs and 1 output; has sizes %v",
+			NumInputs, net.Sizes)
+	}
+	if len(net.Weights) != n-1 || len(net.Biases) != n-1 {
+		return fmt.Errorf("network with %d layers has %d weight and %d bias layers",
+			n, len(net.Weights), len(net.Biases))
+	}
+	for l := 0; l+1 < n; l++ {
+		if len(net.Weights[l]) != net.Sizes[l]*net.Sizes[l+1] || len(net.Biases[l]) != net.Sizes[l+1] {
+			return fmt.Errorf("layer %d has the wrong number of weights or biases", l+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package nn
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/search"
+)
+
+// position is a small endgame: red's pawn and guard against black's
+// elephant, with one of each color still face down.
+func position(toMove string) game.State {
+	return game.NewState(toMove, [][]string{
+		{"p", ".", "g", ".", ".", ".", ".", "?"},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{"E", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "?"},
+	}, []string{"q", "q", "p", "p", "p", "p", "h", "h", "c", "c", "e", "e", "g",
+		"Q", "Q", "P", "P", "P", "P", "H", "H", "C", "C", "E", "G", "G", "K"})
+}
+
+func TestInputs(t *testing.T) {
+	red, black := position("Red"), position("Black")
+	x, y := Inputs(&red), Inputs(&black)
+	if len(x) != NumInputs {
+		t.Fatalf("expected %d inputs; got %d", NumInputs, len(x))
+	}
+	pawn := kindOf(&game.RedTeam, game.RedPawn)
+	if x[pawn] != 1 || y[kinds+pawn] != 1 {
+		t.Errorf("red's pawn on A1 should be ours for red and theirs for black")
+	}
+	if x[7*squareInputs+2*kinds] != 1 {
+		t.Errorf("A8 should be face down")
+	}
+	if x[redInput] != 1 || y[redInput] != 0 {
+		t.Errorf("the side to move is wrong")
+	}
+	downPawns := x[downInputs+pawn]*starting[pawn] + x[downInputs+kinds+pawn]*starting[pawn]
+	if downPawns != 1 {
+		t.Errorf("expected one face-down pawn; got %g", downPawns)
+	}
+}
+
+// TestGradient compares backpropagation with finite differences.
+func TestGradient(t *testing.T) {
+	net := NewNetwork(rand.New(rand.NewSource(1)), 8, 4)
+	tr := NewTrainer(net)
+	gs := position("Red")
+	s := Sample{Inputs: Inputs(&gs), Result: 1}
+	g := tr.zeros()
+	tr.backward(s, g)
+	const h = 1e-6
+	for l := range net.Weights {
+		for _, i := range []int{0, 3, len(net.Weights[l]) - 1} {
+			w := net.Weights[l][i]
+			net.Weights[l][i] = w + h
+			up := tr.Loss([]Sample{s})
+			net.Weights[l][i] = w - h
+			down := tr.Loss([]Sample{s})
+			net.Weights[l][i] = w
+			if numeric := (up - down) / (2 * h); math.Abs(numeric-g.weights[l][i]) > 1e-4 {
+				t.Errorf("layer %d weight %d: backpropagation says %g; finite differences %g",
+					l, i, g.weights[l][i], numeric)
+			}
+		}
+	}
+}
+
+func TestTrain(t *testing.T) {
+	red, black := position("Red"), position("Black")
+	samples := []Sample{{Inputs(&red), 1}, {Inputs(&black), 0}}
+	net := NewNetwork(rand.New(rand.NewSource(1)), 16)
+	tr := NewTrainer(net)
+	tr.Rate = 0.1
+	before := tr.Loss(samples)
+	after := tr.Train(samples, 200, rand.New(rand.NewSource(2)))
+	if after >= before/4 {
+		t.Errorf("training should fit two samples; loss went from %g to %g", before, after)
+	}
+	e := NewEvaluator(net)
+	if e.Evaluate(&red) <= 0 || e.Evaluate(&black) >= 0 {
+		t.Errorf("expected red to be winning and black losing; got %d and %d",
+			e.Evaluate(&red), e.Evaluate(&black))
+	}
+}
+
+func TestNetworkFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	net := NewNetwork(rand.New(rand.NewSource(1)), 8)
+	path := filepath.Join(dir, "net.json")
+	if err := net.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := position("Black")
+	if loaded.Predict(&gs) != net.Predict(&gs) {
+		t.Errorf("the network changed in the file")
+	}
+
+	net.Sizes[0]--
+	if err := net.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Errorf("expected an error loading a network with the wrong inputs")
+	}
+}
+
+func TestSearchWithNetwork(t *testing.T) {
+	var eval search.Evaluator = NewEvaluator(NewNetwork(rand.New(rand.NewSource(1)), 8))
+	if _, ok := eval.(search.Bounded); !ok {
+		t.Fatalf("the evaluator should promise its bounds, for Star pruning")
+	}
+	gs := position("Red")
+	result := search.NewExpectimax(eval, search.Star2).Search(&gs, 2)
+	if result.Score < -Scale || result.Score > Scale {
+		t.Errorf("score %d is out of the network's bounds", result.Score)
+	}
+}
@@ -0,0 +1,152 @@
+package nn
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/perlmonger42/greedy-bot/dataset"
+)
+
+// Sample is a position reduced to what training needs.
+type Sample struct {
+	Inputs []float64
+	Result float64
+}
+
+// NewSamples computes the inputs of every position.
+func NewSamples(positions []dataset.Position) []Sample {
+	samples := make([]Sample, len(positions))
+	for i := range positions {
+		gs := positions[i].State()
+		samples[i] = Sample{Inputs: Inputs(&gs), Result: positions[i].Result}
+	}
+	return samples
+}
+
+// Trainer fits a network to samples by minibatch stochastic gradient
+// descent on the cross-entropy between its predictions and the results.
+type Trainer struct {
+	Net   *Network
+	Rate  float64 // the learning rate
+	Batch int     // samples per gradient step
+	Decay float64 // L2 weight decay, applied at every step
+	// Progress, if set, is called after every epoch with the loss over the
+	// training samples.
+	Progress func(epoch int, loss float64)
+}
+
+func NewTrainer(net *Network) *Trainer {
+	return &Trainer{Net: net, Rate: 0.01, Batch: 32, Decay: 1e-5}
+}
+
+// Loss is the network's mean cross-entropy over the samples.
+func (t *Trainer) Loss(samples []Sample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	const eps = 1e-12
+	sum := 0.0
+	for _, s := range samples {
+		acts := t.Net.forward(s.Inputs)
+		p := acts[len(acts)-1][0]
+		sum -= s.Result*math.Log(p+eps) + (1-s.Result)*math.Log(1-p+eps)
+	}
+	return sum / float64(len(samples))
+}
+
+// Train makes the given number of passes over the samples, shuffling them
+// with r before each, and returns the final loss.
+func (t *Trainer) Train(samples []Sample, epochs int, r *rand.Rand) float64 {
+	order := make([]int, len(samples))
+	for i := range order {
+		order[i] = i
+	}
+	grads := t.zeros()
+	for epoch := 1; epoch <= epochs; epoch++ {
+		r.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		for start := 0; start < len(order); start += t.Batch {
+			end := start + t.Batch
+			if end > len(order) {
+				end = len(order)
+			}
+			for _, i := range order[start:end] {
+				t.backward(samples[i], grads)
+			}
+			t.step(grads, end-start)
+		}
+		if t.Progress != nil {
+			t.Progress(epoch, t.Loss(samples))
+		}
+	}
+	return t.Loss(samples)
+}
+
+// gradients has the shape of a network's weights and biases.
+type gradients struct {
+	weights, biases [][]float64
+}
+
+func (t *Trainer) zeros() gradients {
+	var g gradients
+	for l := range t.Net.Weights {
+		g.weights = append(g.weights, make([]float64, len(t.Net.Weights[l])))
+		g.biases = append(g.biases, make([]float64, len(t.Net.Biases[l])))
+	}
+	return g
+}
+
+// backward adds the gradient of the loss on one sample to g.
+func (t *Trainer) backward(s Sample, g gradients) {
+	net := t.Net
+	acts := net.forward(s.Inputs)
+	last := len(net.Weights) - 1
+	// With a sigmoid output and cross-entropy loss, the gradient with
+	// respect to the output's weighted input is simply the error.
+	delta := []float64{acts[last+1][0] - s.Result}
+	for l := last; l >= 0; l-- {
+		in, w, n := acts[l], net.Weights[l], net.Sizes[l]
+		var back []float64
+		if l > 0 {
+			back = make([]float64, n)
+		}
+		for j, d := range delta {
+			if d == 0 {
+				continue
+			}
+			g.biases[l][j] += d
+			row := w[j*n : (j+1)*n]
+			grow := g.weights[l][j*n : (j+1)*n]
+			for i, a := range in {
+				if a != 0 {
+					grow[i] += d * a
+				}
+				if back != nil {
+					back[i] += d * row[i]
+				}
+			}
+		}
+		for i := range back {
+			if in[i] <= 0 { // the derivative of ReLU
+				back[i] = 0
+			}
+		}
+		delta = back
+	}
+}
+
+// step applies the gradients accumulated over n samples, and clears them.
+func (t *Trainer) step(g gradients, n int) {
+	rate := t.Rate / float64(n)
+	for l := range t.Net.Weights {
+		w := t.Net.Weights[l]
+		for i, d := range g.weights[l] {
+			w[i] -= rate*d + t.Rate*t.Decay*w[i]
+			g.weights[l][i] = 0
+		}
+		b := t.Net.Biases[l]
+		for j, d := range g.biases[l] {
+			b[j] -= rate * d
+			g.biases[l][j] = 0
+		}
+	}
+}
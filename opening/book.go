@@ -0,0 +1,192 @@
+// Package opening learns which squares are good to flip early in the game,
+// when nearly every piece is still face down and every flip looks the same
+// to a materiel count.
+//
+// A flip is classified by its square and by its relation to the pieces
+// already revealed around it: whether it is next to one of ours, next to
+// one of theirs, both, or neither. The book keeps, for each class, how
+// often the player who made such a flip went on to win. Rarely seen
+// squares fall back on the statistics of their region of the board: the
+// corners, the edges, or the centre.
+package opening
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/selfplay"
+)
+
+// Regions of the board.
+const (
+	Corner = iota
+	Edge
+	Centre
+	numRegions
+)
+
+// Relations of a square to the face-up pieces next to it.
+const (
+	Alone      = iota // no face-up neighbours
+	NearOurs          // next to one of the flipper's pieces
+	NearTheirs        // next to one of the opponent's pieces
+	NearBoth
+	numRelations
+)
+
+// DefaultMinFaceDown is how many squares must still be face down for the
+// book to be consulted: three quarters of the board.
+const DefaultMinFaceDown = 24
+
+// Stat counts the games in which a class of flip was made, and the points
+// the flipper went on to score (1 per win, one half per draw).
+type Stat struct {
+	Plays  float64 `json:"plays"`
+	Points float64 `json:"points"`
+}
+
+// Book is a learned preference for flips in the opening.
+type Book struct {
+	// MinFaceDown is the fewest face-down squares at which the book still
+	// applies.
+	MinFaceDown int `json:"minFaceDown"`
+	// MinPlays is how many plays a square needs before its own statistics
+	// are trusted over its region's.
+	MinPlays float64 `json:"minPlays"`
+
+	Squares [32][numRelations]Stat         `json:"squares"`
+	Regions [numRegions][numRelations]Stat `json:"regions"`
+}
+
+func NewBook() *Book {
+	return &Book{MinFaceDown: DefaultMinFaceDown, MinPlays: 20}
+}
+
+// Region classifies a square as a corner, an edge, or the centre.
+func Region(row, col int) int {
+	onEdgeRow, onEdgeCol := row == 0 || row == 3, col == 0 || col == 7
+	switch {
+	case onEdgeRow && onEdgeCol:
+		return Corner
+	case onEdgeRow || onEdgeCol:
+		return Edge
+	}
+	return Centre
+}
+
+// Relation classifies a square by the face-up pieces next to it, from the
+// point of view of us. Before the first flip, everything is Alone.
+func Relation(board *game.Board, us *game.Team, row, col int) int {
+	if us == nil {
+		return Alone
+	}
+	ours, theirs := false, false
+	for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		r, c := row+d[0], col+d[1]
+		if r < 0 || r >= 4 || c < 0 || c >= 8 {
+			continue
+		}
+		p := board.At(r, c)
+		if us.Contains(p) {
+			ours = true
+		} else if us.Other().Contains(p) {
+			theirs = true
+		}
+	}
+	switch {
+	case ours && theirs:
+		return NearBoth
+	case ours:
+		return NearOurs
+	case theirs:
+		return NearTheirs
+	}
+	return Alone
+}
+
+// Applies tells whether the book should be consulted in gs: enough of the
+// board is still face down.
+func (b *Book) Applies(gs *game.State) bool {
+	down := 0
+	gs.Board.Each(func(p game.Piece) {
+		if p == game.FaceDown {
+			down++
+		}
+	})
+	return down >= b.MinFaceDown
+}
+
+// Weight is the book's estimate of the flipper's winning chances after the
+// flip m in gs, between 0 and 1. With no data it is one half.
+func (b *Book) Weight(gs *game.State, m move.T) float64 {
+	r, c := m.From().Row(), m.From().Col()
+	rel := Relation(&gs.Board, gs.Us, r, c)
+	s := b.Squares[r*8+c][rel]
+	if s.Plays < b.MinPlays {
+		s = b.Regions[Region(r, c)][rel]
+	}
+	// Laplace smoothing keeps a handful of games from deciding everything.
+	return (s.Points + 1) / (s.Plays + 2)
+}
+
+// Best returns the flips among moves that the book likes best; there may
+// be several if the book can't tell them apart, and there are none if
+// moves has no flips.
+func (b *Book) Best(gs *game.State, moves []move.T) []move.T {
+	best, bestWeight := []move.T{}, -1.0
+	for _, m := range moves {
+		if m.Action() != move.Flip {
+			continue
+		}
+		if w := b.Weight(gs, m); w > bestWeight {
+			best, bestWeight = []move.T{m}, w
+		} else if w == bestWeight {
+			best = append(best, m)
+		}
+	}
+	return best
+}
+
+// Learn adds the opening flips of the recorded games to the book's
+// statistics.
+func (b *Book) Learn(records []selfplay.Record) {
+	for i := range records {
+		rec := &records[i]
+		for _, ply := range rec.Plies {
+			if ply.Move.Action() != move.Flip || !b.Applies(&ply.Before) {
+				continue
+			}
+			r, c := ply.Move.From().Row(), ply.Move.From().Col()
+			rel := Relation(&ply.Before.Board, ply.Before.Us, r, c)
+			points := rec.Result(ply.Mover)
+			for _, s := range []*Stat{&b.Squares[r*8+c][rel], &b.Regions[Region(r, c)][rel]} {
+				s.Plays++
+				s.Points += points
+			}
+		}
+	}
+}
+
+// Load reads a book written by Save.
+func Load(path string) (*Book, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := NewBook()
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Save writes the book to path as JSON.
+func (b *Book) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
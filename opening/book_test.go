@@ -0,0 +1,109 @@
+package opening
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/selfplay"
+)
+
+func faceDownState(toMove string) game.State {
+	rows := make([][]string, 4)
+	for r := range rows {
+		rows[r] = []string{"?", "?", "?", "?", "?", "?", "?", "?"}
+	}
+	return game.NewState(toMove, rows, []string{})
+}
+
+func TestRegion(t *testing.T) {
+	cases := map[[2]int]int{
+		{0, 0}: Corner, {3, 7}: Corner, {0, 3}: Edge, {2, 0}: Edge, {1, 1}: Centre, {2, 6}: Centre,
+	}
+	for sq, want := range cases {
+		if have := Region(sq[0], sq[1]); have != want {
+			t.Errorf("region of %v should be %d; got %d", sq, want, have)
+		}
+	}
+}
+
+func TestRelation(t *testing.T) {
+	gs := faceDownState("Red")
+	gs.Board[0][1] = game.RedPawn
+	gs.Board[1][0] = game.BlackPawn
+	gs.Board[2][2] = game.BlackKing
+	if have := Relation(&gs.Board, gs.Us, 0, 0); have != NearBoth {
+		t.Errorf("A1 should be near both; got %d", have)
+	}
+	if have := Relation(&gs.Board, gs.Us, 2, 1); have != NearTheirs {
+		t.Errorf("B3 should be near theirs; got %d", have)
+	}
+	if have := Relation(&gs.Board, gs.Us, 3, 7); have != Alone {
+		t.Errorf("H4 should be alone; got %d", have)
+	}
+}
+
+func TestLearnPrefersWinningSquares(t *testing.T) {
+	gs := faceDownState("Red")
+	records := []selfplay.Record{}
+	for i := 0; i < 30; i++ {
+		records = append(records,
+			selfplay.Record{Winner: "Red", Plies: []selfplay.Ply{
+				{Before: gs, Mover: "Red", Move: move.NewFlip(3, 7)}}},
+			selfplay.Record{Winner: "Black", Plies: []selfplay.Ply{
+				{Before: gs, Mover: "Red", Move: move.NewFlip(1, 3)}}})
+	}
+	book := NewBook()
+	book.Learn(records)
+
+	// The other corners have no data of their own, but share H4's region,
+	// so they are just as good.
+	best := book.Best(&gs, move.LegalMoves(gs.Us, gs.Them, gs.Board))
+	if len(best) != 4 {
+		t.Errorf("expected the book to prefer the 4 corners; got %d moves", len(best))
+	}
+	for _, m := range best {
+		if Region(m.From().Row(), m.From().Col()) != Corner {
+			t.Errorf("expected only corners; got %s", m.String())
+		}
+	}
+	if w, centre := book.Weight(&gs, move.NewFlip(3, 7)), book.Weight(&gs, move.NewFlip(1, 3)); w <= centre {
+		t.Errorf("H4 (%f) should beat D2 (%f)", w, centre)
+	}
+
+	dir, err := ioutil.TempDir("", "opening")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "book.json")
+	if err := book.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Squares[31][Alone] != book.Squares[31][Alone] {
+		t.Errorf("expected %+v after loading; got %+v",
+			book.Squares[31][Alone], loaded.Squares[31][Alone])
+	}
+}
+
+func TestApplies(t *testing.T) {
+	gs := faceDownState("Red")
+	book := NewBook()
+	if !book.Applies(&gs) {
+		t.Errorf("the book should apply to an all-face-down board")
+	}
+	for c := 0; c < 8; c++ {
+		gs.Board[0][c] = game.None
+		gs.Board[1][c] = game.None
+	}
+	if book.Applies(&gs) {
+		t.Errorf("the book shouldn't apply with half the board revealed")
+	}
+}
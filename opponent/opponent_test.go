@@ -0,0 +1,95 @@
+package opponent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/command"
+)
+
+func board(rows ...string) [][]string {
+	b := [][]string{}
+	for _, row := range rows {
+		r := []string{}
+		for _, c := range row {
+			r = append(r, string(c))
+		}
+		b = append(b, r)
+	}
+	return b
+}
+
+func TestObserve(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		bc   command.BoardCommand
+		want Profile
+	}{
+		{"flip", command.BoardCommand{
+			Board:    board("P???????", "????????", "????????", "????????"),
+			LastMove: []string{"?A1"},
+		}, Profile{Moves: 1, Flips: 1}},
+		{"flee", command.BoardCommand{
+			Board:    board("e.H.....", "........", "........", "........"),
+			LastMove: []string{"B1>C1"},
+		}, Profile{Moves: 1, Chased: 1, Fled: 1}},
+		{"ignore", command.BoardCommand{
+			Board:    board("eH......", "........", "........", ".......P"),
+			LastMove: []string{"H3", "H4"},
+		}, Profile{Moves: 1, Chased: 1, Ignored: 1, Hanging: 1}},
+		{"capture", command.BoardCommand{
+			Board:    board("C.......", "........", "........", "........"),
+			Dead:     []string{"h"},
+			LastMove: []string{"A2>A1"},
+			LastDead: "h",
+		}, Profile{Moves: 1, Captures: 1}},
+	} {
+		have, ok := Observe(&c.bc, "Black")
+		if !ok {
+			t.Errorf("%s: the move wasn't recognized", c.name)
+		} else if have != c.want {
+			t.Errorf("%s: expected %+v; got %+v", c.name, c.want, have)
+		}
+	}
+
+	bad := command.BoardCommand{
+		Board:    board("e.H.....", "........", "........", "........"),
+		LastMove: []string{"B1>D1"}, // a horse can't jump
+	}
+	if _, ok := Observe(&bad, "Black"); ok {
+		t.Errorf("expected an impossible move not to be recognized")
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opponent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "opponents.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("alice", Profile{Games: 1, Moves: 30, Flips: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("alice", Profile{Games: 1, Moves: 20, Flips: 5}); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := again.Get("alice")
+	if p.Games != 2 || p.Moves != 50 || p.Flips != 15 || !p.Known() {
+		t.Errorf("expected two games' worth of moves; got %+v", p)
+	}
+	if unknown := again.Get("bob"); unknown.Known() || unknown.FlipRate() != 0.5 {
+		t.Errorf("expected nothing to be known of a new opponent; got %+v", unknown)
+	}
+}
@@ -0,0 +1,177 @@
+// Package opponent learns how each opponent plays, from the moves they make,
+// and remembers it from one game to the next.
+package opponent
+
+import (
+	"strings"
+
+	"github.com/perlmonger42/greedy-bot/command"
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// MinMoves is how many of an opponent's moves must be seen before their
+// profile is worth acting on.
+const MinMoves = 20
+
+// Profile counts what an opponent has done. A single observed move is
+// itself a Profile, which Add accumulates.
+type Profile struct {
+	Games    int `json:"games"`
+	Moves    int `json:"moves"` // moves of every kind
+	Flips    int `json:"flips"`
+	Captures int `json:"captures"`
+	// Hanging counts the moves after which we could capture something.
+	Hanging int `json:"hanging"`
+	// Chased counts the moves they made with a piece of theirs under
+	// attack, and the rest say how they responded: by moving the piece
+	// (or one of them) away, by capturing something, or by neither.
+	Chased    int `json:"chased"`
+	Fled      int `json:"fled"`
+	Countered int `json:"countered"`
+	Ignored   int `json:"ignored"`
+}
+
+// Add accumulates o's counts into p.
+func (p *Profile) Add(o Profile) {
+	p.Games += o.Games
+	p.Moves += o.Moves
+	p.Flips += o.Flips
+	p.Captures += o.Captures
+	p.Hanging += o.Hanging
+	p.Chased += o.Chased
+	p.Fled += o.Fled
+	p.Countered += o.Countered
+	p.Ignored += o.Ignored
+}
+
+// Known says whether enough moves have been seen to trust the rates.
+func (p *Profile) Known() bool {
+	return p.Moves >= MinMoves
+}
+
+// The rates are smoothed toward one half, so that a few moves don't
+// give a confident answer.
+
+// FlipRate is the fraction of their moves that are flips.
+func (p *Profile) FlipRate() float64 {
+	return rate(p.Flips, p.Moves)
+}
+
+// HangRate is the fraction of their moves that leave us a capture.
+func (p *Profile) HangRate() float64 {
+	return rate(p.Hanging, p.Moves)
+}
+
+// FleeRate is the fraction of threats they respond to by moving away.
+func (p *Profile) FleeRate() float64 {
+	return rate(p.Fled, p.Chased)
+}
+
+// IgnoreRate is the fraction of threats they do nothing about.
+func (p *Profile) IgnoreRate() float64 {
+	return rate(p.Ignored, p.Chased)
+}
+
+func rate(n, of int) float64 {
+	return (float64(n) + 1) / (float64(of) + 2)
+}
+
+// Observe describes the opponent's move reported by a board command, when
+// it is our turn and the opponent's color is color. It reconstructs the
+// position they moved in from the board, the move, and the piece it
+// captured, so it needs no memory of earlier boards.
+func Observe(bc *command.BoardCommand, color string) (Profile, bool) {
+	before, m, ok := reconstruct(bc, color)
+	if !ok {
+		return Profile{}, false
+	}
+	o := Profile{Moves: 1}
+	switch m.Action() {
+	case move.Flip:
+		o.Flips = 1
+	case move.Take:
+		o.Captures = 1
+	}
+
+	// Which of their pieces were we attacking when they moved?
+	threatened := map[move.Location]bool{}
+	for _, c := range move.Captures(before.Them, before.Us, before.Board) {
+		threatened[c.To()] = true
+	}
+	if len(threatened) > 0 {
+		o.Chased = 1
+		switch {
+		case m.Action() != move.Flip && threatened[m.From()]:
+			o.Fled = 1
+		case m.Action() == move.Take:
+			o.Countered = 1
+		default:
+			o.Ignored = 1
+		}
+	}
+
+	after := game.NewState(other(color), bc.Board, bc.Dead)
+	if len(move.Captures(after.Us, after.Them, after.Board)) > 0 {
+		o.Hanging = 1
+	}
+	return o, true
+}
+
+// reconstruct undoes the last move of a board command, returning the
+// position it was played in, with color to move, and the move itself.
+func reconstruct(bc *command.BoardCommand, color string) (game.State, move.T, bool) {
+	desc := strings.TrimPrefix(strings.ToUpper(strings.Join(bc.LastMove, ">")), "?")
+	if desc == "" || color == "" {
+		return game.State{}, move.T{}, false
+	}
+	squares := strings.Split(desc, ">")
+	from, err := move.ParseLocation(squares[0])
+	if err != nil || len(squares) > 2 || len(bc.Board) != 4 {
+		return game.State{}, move.T{}, false
+	}
+	board := make([][]string, len(bc.Board))
+	for r, row := range bc.Board {
+		if len(row) != 8 {
+			return game.State{}, move.T{}, false
+		}
+		board[r] = append([]string{}, row...)
+	}
+	dead := append([]string{}, bc.Dead...)
+	var to move.Location
+	if len(squares) == 1 {
+		board[from.Row()][from.Col()] = "?"
+	} else {
+		if to, err = move.ParseLocation(squares[1]); err != nil {
+			return game.State{}, move.T{}, false
+		}
+		board[from.Row()][from.Col()] = board[to.Row()][to.Col()]
+		board[to.Row()][to.Col()] = "."
+		if bc.LastDead != "" {
+			board[to.Row()][to.Col()] = bc.LastDead
+			for i := len(dead) - 1; i >= 0; i-- {
+				if dead[i] == bc.LastDead {
+					dead = append(dead[:i], dead[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	before := game.NewState(color, board, dead)
+	for _, m := range move.LegalMoves(before.Us, before.Them, before.Board) {
+		if m.From() != from {
+			continue
+		}
+		if (len(squares) == 1) == (m.Action() == move.Flip) && (m.Action() == move.Flip || m.To() == to) {
+			return before, m, true
+		}
+	}
+	return game.State{}, move.T{}, false
+}
+
+func other(color string) string {
+	if strings.ToLower(color) == "red" {
+		return "Black"
+	}
+	return "Red"
+}
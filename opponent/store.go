@@ -0,0 +1,73 @@
+package opponent
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store keeps every opponent's profile in a JSON file, keyed by the name
+// they play under. It is safe to share among sessions.
+type Store struct {
+	path     string
+	mu       sync.Mutex
+	profiles map[string]Profile
+}
+
+// Open reads the profiles in the file at path, which needn't exist yet.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, profiles: map[string]Profile{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.profiles); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the profile of the named opponent, which is empty if they
+// haven't been seen before.
+func (s *Store) Get(name string) Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profiles[name]
+}
+
+// Add accumulates o into the named opponent's profile, and saves the file.
+func (s *Store) Add(name string, o Profile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.profiles[name]
+	p.Add(o)
+	s.profiles[name] = p
+	return s.save()
+}
+
+// save writes the file by way of a temporary one, so that a crash can't
+// leave it half written.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
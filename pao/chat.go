@@ -0,0 +1,177 @@
+package pao
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/bot"
+	"github.com/perlmonger42/greedy-bot/command"
+)
+
+// Explainer is implemented by bots that can say why they chose their last
+// move, for the !explain chat command. The explanation finishes the
+// sentence "I played that because ...".
+type Explainer interface {
+	Explain() string
+}
+
+// Levels lets players change the bot's difficulty with the !level chat
+// command. NewBot builds a bot for the named level.
+type Levels interface {
+	Names() []string
+	NewBot(name string) (Bot, error)
+}
+
+// Players can send a burst of chatBurst chat commands, and one more every
+// chatRefill; the bot doesn't answer any beyond that.
+const (
+	chatBurst  = 3
+	chatRefill = 5 * time.Second
+)
+
+// rateLimit is a token bucket for answering chat commands.
+type rateLimit struct {
+	tokens float64
+	last   time.Time
+}
+
+func (r *rateLimit) allow(now time.Time) bool {
+	if r.last.IsZero() {
+		r.tokens = chatBurst
+	} else {
+		r.tokens += float64(now.Sub(r.last)) / float64(chatRefill)
+		if r.tokens > chatBurst {
+			r.tokens = chatBurst
+		}
+	}
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// say sends a chat message.
+func (svc *Service) say(format string, args ...interface{}) error {
+	return svc.send(command.Command{Action: "chat", Argument: fmt.Sprintf(format, args...)})
+}
+
+// greet welcomes the players to a new game.
+func (svc *Service) greet() error {
+	if svc.games > 1 {
+		return svc.say("Rematch! Good luck.")
+	}
+	return svc.say("Hi, I'm the %s bot. Good luck! Say !help to see what else I can do.", svc.bot.Name())
+}
+
+// chatCommand answers a chat message like "!hint" or "!level hard".
+func (svc *Service) chatCommand(cc *command.ChatCommand) error {
+	fields := strings.Fields(cc.Message)
+	name, args := strings.ToLower(strings.TrimPrefix(fields[0], "!")), fields[1:]
+	if !svc.chatLimit.allow(time.Now()) {
+		svc.Log.Debug("not answering chat command", "command", name, "player", cc.Player)
+		return nil
+	}
+	svc.Log.Debug("chat command", "command", name, "args", strings.Join(args, " "), "player", cc.Player)
+	var reply string
+	switch name {
+	case "help":
+		reply = "Ask me for my !eval of the game, a !hint, or to !explain my last move."
+		if svc.Levels != nil {
+			reply += " You can change my !level, too."
+		}
+	case "eval":
+		reply = svc.evalReply()
+	case "hint":
+		reply = svc.hintReply()
+	case "level":
+		reply = svc.levelReply(args)
+	case "explain":
+		reply = svc.explainReply()
+	default:
+		reply = fmt.Sprintf("I don't know !%s. Try !help.", name)
+	}
+	return svc.say("%s", reply)
+}
+
+func (svc *Service) evalReply() string {
+	switch {
+	case svc.state == nil:
+		return "I haven't moved yet this game."
+	case svc.score > 0:
+		return fmt.Sprintf("I think I'm ahead, by %d.", svc.score)
+	case svc.score < 0:
+		return fmt.Sprintf("I think I'm behind, by %d.", -svc.score)
+	default:
+		return "I think we're even."
+	}
+}
+
+// hintReply suggests a move for the opponent, in the position after our
+// last move, going by a greedy bot's ranking. After a flip, we don't know
+// the position until our next turn.
+func (svc *Service) hintReply() string {
+	switch {
+	case !svc.playing:
+		return "We're between games."
+	case svc.state == nil:
+		return "Any flip is as good as another."
+	case svc.after == nil:
+		return "I can't see what my flip turned up yet, so I can't say."
+	}
+	ranked := bot.NewGreedyBot().RankMoves(svc.after)
+	if len(ranked) == 0 {
+		return "You have no moves, I'm afraid."
+	}
+	best := ranked[0]
+	return fmt.Sprintf("My hint: %s.", best.String())
+}
+
+func (svc *Service) levelReply(args []string) string {
+	if svc.Levels == nil {
+		return "Sorry, my level can't be changed here."
+	}
+	names := strings.Join(svc.Levels.Names(), ", ")
+	if len(args) == 0 {
+		return fmt.Sprintf("Say !level and one of: %s.", names)
+	}
+	b, err := svc.Levels.NewBot(args[0])
+	if err != nil {
+		svc.Log.Debug("can't change level", "level", args[0], "err", err)
+		return fmt.Sprintf("I don't know level %q. Try one of: %s.", args[0], names)
+	}
+	svc.setBot(b)
+	svc.Log.Info("level changed", "level", args[0], "bot", b.Name())
+	return fmt.Sprintf("OK, I'm playing at level %s now.", args[0])
+}
+
+// setBot replaces the bot, from its next move on.
+func (svc *Service) setBot(b Bot) {
+	svc.stopPondering()
+	svc.pondering = nil
+	if reseeder, ok := b.(Reseeder); ok {
+		reseeder.Reseed(svc.seed + int64(svc.games))
+	}
+	svc.statusMu.Lock()
+	defer svc.statusMu.Unlock()
+	svc.bot = b
+}
+
+func (svc *Service) explainReply() string {
+	if svc.state == nil {
+		return "I haven't moved yet this game."
+	}
+	return fmt.Sprintf("I played %s because %s.", svc.lastMove.String(), svc.why)
+}
+
+// explain says why the bot chose its move, in which it expects svc.score.
+func (svc *Service) explain() string {
+	if explainer, ok := svc.bot.(Explainer); ok {
+		if why := explainer.Explain(); why != "" {
+			return why
+		}
+	}
+	return fmt.Sprintf("it scored %+d", svc.score)
+}
@@ -0,0 +1,135 @@
+package pao
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/command"
+)
+
+func TestRateLimit(t *testing.T) {
+	var r rateLimit
+	start := time.Now()
+	for i := 0; i < chatBurst; i++ {
+		if !r.allow(start) {
+			t.Fatalf("refused command %d of the first burst", i+1)
+		}
+	}
+	if r.allow(start) {
+		t.Errorf("allowed more than a burst at once")
+	}
+	if r.allow(start.Add(chatRefill / 2)) {
+		t.Errorf("allowed a command before a refill")
+	}
+	if !r.allow(start.Add(chatRefill)) {
+		t.Errorf("refused a command after a refill")
+	}
+	if r.allow(start.Add(chatRefill)) {
+		t.Errorf("allowed two commands for one refill")
+	}
+	later := start.Add(100 * chatRefill)
+	for i := 0; i < chatBurst; i++ {
+		if !r.allow(later) {
+			t.Fatalf("refused command %d of a burst after a long wait", i+1)
+		}
+	}
+	if r.allow(later) {
+		t.Errorf("saved up more than a burst")
+	}
+}
+
+// chat sends a chat command from the opponent, and returns the bot's answer.
+func (p *fakePao) chat(message string) string {
+	p.t.Helper()
+	p.send(command.ChatCommand{Action: "chat", Player: "alice", Color: "Black", Message: message})
+	return p.expect("chat")
+}
+
+func TestChatCommands(t *testing.T) {
+	p := dial(t, newService(&testBot{name: "Test"}))
+	for _, c := range []struct {
+		message, want string
+	}{
+		{"!help", "Ask me for my !eval of the game, a !hint, or to !explain my last move."},
+		{"!EVAL", "I haven't moved yet this game."},
+		{"!dance now", "I don't know !dance. Try !help."},
+	} {
+		if got := p.chat(c.message); got != c.want {
+			t.Errorf("answered %q with %q, want %q", c.message, got, c.want)
+		}
+	}
+}
+
+// testLevels offers a "hard" level, played by a testBot named Hard.
+type testLevels struct {
+	hard *testBot
+}
+
+func (l testLevels) Names() []string {
+	return []string{"easy", "hard"}
+}
+
+func (l testLevels) NewBot(name string) (Bot, error) {
+	if name != "hard" {
+		return nil, fmt.Errorf("no level %q", name)
+	}
+	return l.hard, nil
+}
+
+func TestLevelCommand(t *testing.T) {
+	hard := &testBot{name: "Hard"}
+	svc := newService(&testBot{name: "Test"})
+	svc.Levels = testLevels{hard}
+	p := dial(t, svc)
+	for _, c := range []struct {
+		message, want string
+	}{
+		{"!level", "Say !level and one of: easy, hard."},
+		{"!level impossible", `I don't know level "impossible". Try one of: easy, hard.`},
+		{"!level hard", "OK, I'm playing at level hard now."},
+	} {
+		if got := p.chat(c.message); got != c.want {
+			t.Errorf("answered %q with %q, want %q", c.message, got, c.want)
+		}
+	}
+	if s := svc.Status(); s.Bot != "Hard" {
+		t.Errorf("playing with %s, want Hard", s.Bot)
+	}
+	p.send(command.BoardCommand{Action: "board", Board: faceDown, YourTurn: true})
+	p.expect("move")
+	seeds, colors := hard.record()
+	if len(colors) != 1 {
+		t.Errorf("the new bot moved %d times, want once", len(colors))
+	}
+	for _, seed := range seeds {
+		if seed != 100 {
+			t.Errorf("the new bot was seeded with %v, want the game's seed, 100", seeds)
+			break
+		}
+	}
+}
+
+func TestHintCommand(t *testing.T) {
+	p := dial(t, newService(&testBot{name: "Test"}))
+	p.send(command.BoardCommand{Action: "board", Board: faceDown, YourTurn: true})
+	p.expect("move")
+	if got, want := p.chat("!hint"), "I can't see what my flip turned up yet, so I can't say."; got != want {
+		t.Errorf("hint after a flip: %q, want %q", got, want)
+	}
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+	p.send(cartToMove)
+	p.expect("move")
+	// The black king, alone, can only move.
+	if got := p.chat("!hint"); !strings.HasPrefix(got, "My hint: BlackKing at H4 moves to") {
+		t.Errorf("hint after a move: %q", got)
+	}
+	p.send(command.GameOverCommand{Action: "gameover", Message: "Red wins", YouWin: true})
+	if gg := p.expect("chat"); gg != "gg" {
+		t.Errorf("said %q after the game, want gg", gg)
+	}
+	if got, want := p.chat("!hint"), "We're between games."; got != want {
+		t.Errorf("hint between games: %q, want %q", got, want)
+	}
+}
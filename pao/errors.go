@@ -0,0 +1,79 @@
+package pao
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolError describes a message that couldn't be read from or written
+// to the Pao server, or that was read but couldn't be made sense of.
+type ProtocolError struct {
+	Stage string // read, timeout, size, decode, or write
+	Kind  string // what the message was meant to be: command, board, ...
+	Text  []byte // the message, if one was read
+	Err   error
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Stage == "decode" {
+		return fmt.Sprintf("malformed %s message: %v (input: %q)", e.Kind, e.Err, e.Text)
+	}
+	return fmt.Sprintf("websocket %s error: %v", e.Stage, e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// Fatal says whether the session must end. Only a malformed message can be
+// passed over; any other error means the connection is broken.
+func (e *ProtocolError) Fatal() bool {
+	return e.Stage != "decode"
+}
+
+// readError wraps an error from reading the connection.
+func readError(err error) *ProtocolError {
+	stage := "read"
+	if err == websocket.ErrReadLimit {
+		stage = "size"
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		stage = "timeout"
+	}
+	return &ProtocolError{Stage: stage, Err: err}
+}
+
+// Policy says what to do with malformed messages.
+type Policy int
+
+const (
+	// LogMalformed logs malformed messages, tells the Pao server about
+	// them, and carries on. It's the default.
+	LogMalformed Policy = iota
+	// IgnoreMalformed passes over malformed messages silently, apart from
+	// counting them.
+	IgnoreMalformed
+	// AbortOnMalformed ends the session, as if the connection had failed.
+	AbortOnMalformed
+)
+
+var policyNames = []string{"log", "ignore", "abort"}
+
+func (p Policy) String() string {
+	if p < 0 || int(p) >= len(policyNames) {
+		return fmt.Sprintf("Policy(%d)", int(p))
+	}
+	return policyNames[p]
+}
+
+// ParsePolicy accepts the names String returns, in any case.
+func ParsePolicy(s string) (Policy, error) {
+	for p, name := range policyNames {
+		if strings.EqualFold(s, name) {
+			return Policy(p), nil
+		}
+	}
+	return LogMalformed, fmt.Errorf("unknown policy %q (want log, ignore, or abort)", s)
+}
@@ -0,0 +1,21 @@
+package pao
+
+import "github.com/perlmonger42/greedy-bot/metrics"
+
+// The service's metrics, which the server publishes at /metrics.
+var (
+	activeSessions = metrics.Default.NewGauge("pao_active_sessions",
+		"Websocket sessions in progress.")
+	gamesStarted = metrics.Default.NewCounter("pao_games_started_total",
+		"Games the bot has started playing.")
+	gamesFinished = metrics.Default.NewCounter("pao_games_finished_total",
+		"Games the bot has finished, by result: win, loss, draw, or abandoned.", "result")
+	moveSeconds = metrics.Default.NewHistogram("pao_move_seconds",
+		"Time taken to choose a move, by bot.", metrics.LatencyBuckets, "bot")
+	protocolErrors = metrics.Default.NewCounter("pao_protocol_errors_total",
+		"Messages that couldn't be handled, by stage: read, timeout, size, decode, or write.", "stage")
+	moveRejections = metrics.Default.NewCounter("pao_move_rejections_total",
+		"Moves the Pao server rejected, by bot.", "bot")
+	botFailures = metrics.Default.NewCounter("pao_bot_failures_total",
+		"Moves for which the bot panicked, and a random move was played instead, by bot.", "bot")
+)
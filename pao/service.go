@@ -2,22 +2,77 @@
 package pao
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"math/rand"
+	"reflect"
 	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/perlmonger42/greedy-bot/bot"
 	"github.com/perlmonger42/greedy-bot/command"
 	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/logging"
 	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/opponent"
+	"github.com/perlmonger42/greedy-bot/search"
 )
 
 type Service struct {
 	conn     *websocket.Conn
 	bot      Bot
 	botColor string
+	seed     int64      // for the bot's random choices, if it makes any
+	rand     *rand.Rand // for ours, should the bot fail
+	games    int        // games begun on this connection
+	playing  bool       // whether a game is in progress
+
+	Log *logging.Logger
+
+	// Codec decodes messages from the Pao server. It's lenient unless
+	// made strict.
+	Codec command.Codec
+	// Malformed says what to do with messages we can't make sense of.
+	Malformed Policy
+
+	// Levels, if set, lets players change the bot with the !level chat
+	// command.
+	Levels    Levels
+	chatLimit rateLimit
+
+	// Etiquette decides when the bot resigns, and offers or accepts draws.
+	Etiquette bot.Etiquette
+	state     *game.State // the position we last moved in
+	score     int         // our evaluation of it
+	lastMove  move.T      // the move we last sent
+	why       string      // why we chose it, for !explain
+	after     *game.State // the position after it, unless it was a flip
+	awaiting  bool        // whether the server might yet reject lastMove
+	lastSent  string      // the action of the last message we sent
+	tried     []move.T    // moves in state the server has rejected
+	rejected  int         // moves the server has rejected this game
+	drawn     bool        // whether a draw has been agreed
+	result    string      // win, loss, or draw, once the game is decided
+
+	statusMu sync.Mutex
+	status   Status
+	stopped  string // "terminated" or "resigned", if stopped from outside
+
+	writeMu sync.Mutex // for writes from outside the session's goroutine
+
+	// Opponents, if set, remembers opponents' profiles between games.
+	Opponents    *opponent.Store
+	players      map[string]string // player names by color, learned from chat
+	opponentName string            // the opponent's name, once known
+	profile      opponent.Profile  // what we know of the opponent's play
+	observed     opponent.Profile  // what we've seen of it this game
+
+	pondering *game.State        // the position after our last move, if pondering it
+	ponderEnd context.CancelFunc // stops the pondering goroutine
+	ponderRun chan struct{}      // closed when the pondering goroutine returns
 }
 
 type Bot interface {
@@ -25,91 +80,754 @@ type Bot interface {
 	ChooseMove(*game.State) move.T
 }
 
+// Status describes a session for the server's operators.
+type Status struct {
+	Bot          string    `json:"bot"`
+	Games        int       `json:"games"`      // games finished on this connection
+	Playing      bool      `json:"playing"`    // a game is in progress
+	Color        string    `json:"color"`      // empty until the first flip
+	Moves        int       `json:"moves"`      // the bot's moves this game
+	Rejections   int       `json:"rejections"` // its moves the server rejected
+	Started      time.Time `json:"started"`
+	LastActivity time.Time `json:"lastActivity"` // when a command last arrived
+}
+
+// Reseeder is implemented by bots that make random choices. A game can be
+// replayed by reseeding the bot with the seed it was played with, and
+// playing the same moves against it.
+type Reseeder interface {
+	Reseed(seed int64)
+}
+
+// OpponentAware is implemented by bots that adapt to how their opponent
+// plays. SetOpponent is called before every move.
+type OpponentAware interface {
+	SetOpponent(p opponent.Profile)
+}
+
+// Ranker is implemented by bots that can list every legal move, best
+// first. When the server rejects a move, the service tries the next one.
+// For bots that can't rank their moves, it goes by a greedy bot's ranking.
+type Ranker interface {
+	RankMoves(state *game.State) []move.T
+}
+
+// Ponderer is implemented by bots that can think on the opponent's time.
+// Ponder is given the position after the bot's own move, and should
+// return promptly once ctx is cancelled.
+type Ponderer interface {
+	Ponder(ctx context.Context, state *game.State)
+}
+
+// ReplyAware is implemented by ponderers that want to know which reply the
+// opponent actually played in the position they pondered, so that they can
+// keep only the work that answers it.
+type ReplyAware interface {
+	OpponentReplied(state *game.State, reply move.T)
+}
+
 func NewService() *Service {
-	return &Service{bot: bot.NewGreedyBot()}
+	return NewServiceFor(bot.NewGreedyBot(), time.Now().UnixNano())
+}
+
+// NewServiceFor builds a service that plays with the given bot, seeding
+// its random choices with seed.
+func NewServiceFor(b Bot, seed int64) *Service {
+	return &Service{bot: b, seed: seed, rand: rand.New(rand.NewSource(seed)),
+		Codec:     command.Codec{Registry: command.ServerMessages},
+		Log:       logging.Default.Sub("pao"),
+		Etiquette: bot.DefaultEtiquette(), players: map[string]string{}}
 }
 
 func (svc *Service) Run(conn *websocket.Conn) {
+	activeSessions.Inc()
+	defer activeSessions.Dec()
+	svc.statusMu.Lock()
 	svc.conn = conn
+	svc.status.Started = time.Now()
+	svc.status.LastActivity = svc.status.Started
+	svc.statusMu.Unlock()
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go svc.keepAlive(done, pingPeriod)
 	svc.PlayGame()
 }
 
+const (
+	// maxMessageSize is the largest message we'll read. Pao's largest, the
+	// board command, is well under 1K.
+	maxMessageSize = 16 * 1024
+	// writeWait is how long a write may take.
+	writeWait = 10 * time.Second
+)
+
+// These are variables so that tests can shorten them.
+var (
+	// pongWait is how long the other side has to answer a ping. If it's
+	// silent for longer, the connection is taken for dead.
+	pongWait = 60 * time.Second
+	// pingPeriod is how often we ping, while waiting for a command.
+	pingPeriod = pongWait * 9 / 10
+	// closeTimeout is how long to wait for the other side to answer a
+	// close frame.
+	closeTimeout = 5 * time.Second
+)
+
+// keepAlive pings the other side every period until done is closed, so
+// that a half-open connection is noticed within pongWait, rather than
+// never.
+func (svc *Service) keepAlive(done chan struct{}, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := svc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				svc.Log.Debug("ping failed", "err", err)
+				return
+			}
+		}
+	}
+}
+
+// Status reports the session's progress. It may be called from any
+// goroutine.
+func (svc *Service) Status() Status {
+	svc.statusMu.Lock()
+	defer svc.statusMu.Unlock()
+	status := svc.status
+	status.Bot = svc.bot.Name()
+	return status
+}
+
+// Terminate ends the session, by closing its connection. It may be called
+// from any goroutine.
+func (svc *Service) Terminate() {
+	svc.statusMu.Lock()
+	defer svc.statusMu.Unlock()
+	if svc.stopped == "" {
+		svc.stopped = "terminated"
+	}
+	if svc.conn != nil {
+		svc.conn.Close()
+	}
+}
+
+// Resign gives up the game, if one is in progress, and says goodbye with a
+// close frame, for a server that is shutting down. The session ends when
+// the other side answers the close frame, or after closeTimeout. Resign
+// may be called from any goroutine.
+func (svc *Service) Resign() {
+	svc.statusMu.Lock()
+	conn, playing := svc.conn, svc.status.Playing
+	if svc.stopped == "" {
+		svc.stopped = "resigned"
+	}
+	svc.statusMu.Unlock()
+	if conn == nil {
+		return
+	}
+	if playing {
+		quit := move.NewQuit()
+		data, _ := outgoing.Encode(quit.Command())
+		svc.writeMu.Lock()
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		conn.WriteMessage(websocket.TextMessage, data)
+		svc.writeMu.Unlock()
+	}
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeTimeout))
+	conn.SetReadDeadline(time.Now().Add(closeTimeout))
+}
+
+// stoppedBy says why the session was stopped from outside, if it was.
+func (svc *Service) stoppedBy() string {
+	svc.statusMu.Lock()
+	defer svc.statusMu.Unlock()
+	return svc.stopped
+}
+
+// updateStatus changes the status under its lock.
+func (svc *Service) updateStatus(change func(*Status)) {
+	svc.statusMu.Lock()
+	defer svc.statusMu.Unlock()
+	change(&svc.status)
+}
+
+// PlayGame serves the connection until it closes, playing as many games as
+// the other side wants: after a gameover, a new color or board command
+// starts a rematch.
 func (svc *Service) PlayGame() {
 	defer func() {
-		msg := fmt.Sprintf("%v - Terminating '%s' bot",
-			time.Now(), svc.bot.Name())
 		if r := recover(); r != nil {
-			fmt.Println(msg+" because of %s", r)
-			debug.PrintStack()
+			svc.Log.Error("terminating", "bot", svc.bot.Name(), "reason", r,
+				"stack", string(debug.Stack()))
+		} else {
+			svc.Log.Info("terminating", "bot", svc.bot.Name(), "games", svc.games)
+		}
+		if svc.playing && svc.result == "" && svc.stoppedBy() == "resigned" {
+			svc.result = "loss"
+		} else if svc.playing && svc.result == "" {
+			svc.result = "abandoned"
 		}
-		fmt.Println(msg)
+		svc.endGame()
 		svc.closeConnection()
 	}()
 
 	for {
-		action, text := svc.GetPaoCommand()
-		fmt.Printf("%v - Command: %v\n", time.Now(), action)
-		switch action {
-		case "gameover":
+		msg, text, err := svc.GetPaoCommand()
+		if err == nil {
+			svc.Log.Debug("command", "type", fmt.Sprintf("%T", msg))
+			svc.updateStatus(func(s *Status) { s.LastActivity = time.Now() })
+			err = svc.runCommand(msg, text)
+		}
+		if err != nil && !svc.handleError(err) {
 			return
-		case "board":
-			if ok := svc.RunBoardCommand(text); !ok {
-				return
-			}
-		case "color":
-			fmt.Printf("Bot color is now %s\n", text)
-			svc.RunColorCommand(text)
+		}
+	}
+}
+
+// beginGame starts a new game, if one isn't in progress, and greets the
+// players. If the last game was decided without a gameover command, as
+// when we resign, it's over.
+func (svc *Service) beginGame() error {
+	if svc.result != "" {
+		svc.endGame()
+	}
+	if svc.playing {
+		return nil
+	}
+	seed := svc.seed + int64(svc.games)
+	if reseeder, ok := svc.bot.(Reseeder); ok {
+		reseeder.Reseed(seed)
+	}
+	svc.games++
+	svc.playing = true
+	svc.updateStatus(func(s *Status) { s.Playing = true })
+	svc.Log.Info("game started", "bot", svc.bot.Name(), "game", svc.games, "seed", seed)
+	gamesStarted.Inc()
+	return svc.greet()
+}
+
+// endGame records the result of the game in progress, if there is one, and
+// forgets it, ready for a rematch.
+func (svc *Service) endGame() {
+	svc.stopPondering()
+	if !svc.playing {
+		return
+	}
+	gamesFinished.Inc(svc.result)
+	svc.Log.Info("game finished", "game", svc.games, "result", svc.result, "rejections", svc.rejected)
+	svc.rememberOpponent()
+	svc.Etiquette.Reset()
+	svc.playing, svc.result, svc.drawn = false, "", false
+	svc.botColor, svc.state, svc.score = "", nil, 0
+	svc.lastMove, svc.awaiting, svc.tried, svc.rejected = move.NewQuit(), false, nil, 0
+	svc.why, svc.after = "", nil
+	svc.pondering = nil
+	// Colors are dealt afresh each game, so we must learn again which
+	// player is which.
+	svc.players, svc.opponentName = map[string]string{}, ""
+	svc.profile, svc.observed = opponent.Profile{}, opponent.Profile{}
+	svc.updateStatus(func(s *Status) {
+		s.Games++
+		s.Playing, s.Color, s.Moves, s.Rejections = false, "", 0, 0
+	})
+}
+
+// runCommand carries out a command from the Pao server, decoded from
+// text.
+func (svc *Service) runCommand(msg interface{}, text []byte) error {
+	var err error
+	switch cmd := msg.(type) {
+	case *command.GameOverCommand:
+		err = svc.RunGameOverCommand(cmd)
+	case *command.BoardCommand:
+		err = svc.RunBoardCommand(cmd)
+	case *command.ColorCommand:
+		err = svc.RunColorCommand(cmd)
+	case *command.DrawCommand:
+		err = svc.RunDrawCommand(cmd)
+	case *command.ChatCommand:
+		err = svc.RunChatCommand(cmd)
+	case *command.ErrorCommand:
+		err = svc.RunErrorCommand(cmd)
+	default:
+		svc.Log.Debug("ignoring command", "text", string(text))
+	}
+	if perr, ok := err.(*ProtocolError); ok && perr.Stage == "decode" && perr.Text == nil {
+		perr.Text = text
+	}
+	return err
+}
+
+// handleError deals with an error from reading or carrying out a command,
+// according to svc.Malformed, and says whether the session can go on.
+func (svc *Service) handleError(err error) bool {
+	perr, ok := err.(*ProtocolError)
+	if !ok {
+		perr = &ProtocolError{Stage: "decode", Kind: "command", Err: err}
+	}
+	if perr.Fatal() {
+		closed := websocket.IsCloseError(perr.Err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
+		switch stopped := svc.stoppedBy(); {
+		case stopped == "terminated":
+			svc.Log.Info("terminated by an operator", "bot", svc.bot.Name())
+		case stopped == "resigned":
+			svc.Log.Info("resigned for shutdown", "bot", svc.bot.Name())
+		case closed:
+			svc.Log.Info("connection closed", "err", perr.Err)
 		default:
-			fmt.Printf("%v - Ignoring: %v\n", time.Now(), string(text))
+			protocolErrors.Inc(perr.Stage)
+			svc.Log.Warn("connection failed", "err", perr)
 		}
+		return false
+	}
+	protocolErrors.Inc(perr.Stage)
+	if perr.Kind == "gameover" && svc.playing {
+		// The game is over, though we can't tell who won.
+		svc.result = "abandoned"
+		svc.endGame()
 	}
+	if svc.Malformed == IgnoreMalformed {
+		return true
+	}
+	svc.Log.Warn("malformed message", "kind", perr.Kind, "err", perr.Err, "text", string(perr.Text))
+	// Pao has no error message, so we complain in the chat.
+	svc.say("I couldn't make sense of that %s message: %v", perr.Kind, perr.Err)
+	return svc.Malformed != AbortOnMalformed
 }
 
+// closeConnection sends a close frame, unless one has been sent already,
+// and waits for the other side's answer before closing the connection.
 func (svc *Service) closeConnection() {
+	svc.Log.Debug("closing connection")
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	svc.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeTimeout))
+	svc.conn.SetReadDeadline(time.Now().Add(closeTimeout))
 	for {
-		fmt.Printf("%v - Closing conn\n", time.Now())
 		if _, _, err := svc.conn.NextReader(); err != nil {
 			svc.conn.Close()
-			fmt.Printf("%v - Closed conn\n", time.Now())
+			svc.Log.Debug("closed connection")
 			break
 		}
 	}
 }
 
-func (svc *Service) GetPaoCommand() (action string, command_text []byte) {
-	var paoCommand command.Command
+// GetPaoCommand reads the next command from the Pao server, and decodes it
+// into the type registered for its action: a *command.BoardCommand, say.
+// Its error, if any, is a *ProtocolError.
+func (svc *Service) GetPaoCommand() (msg interface{}, text []byte, err error) {
+	// The bot may have been thinking a while, so the other side gets a
+	// full pongWait from now to answer our pings.
+	svc.conn.SetReadDeadline(time.Now().Add(pongWait))
+	_, text, err = svc.conn.ReadMessage()
+	if err != nil {
+		return nil, nil, readError(err)
+	}
+	msg, err = svc.Codec.Decode(text)
+	if derr, ok := err.(*command.DecodeError); ok {
+		kind := derr.Action
+		if kind == "" {
+			kind = "command"
+		}
+		return nil, text, &ProtocolError{Stage: "decode", Kind: kind, Text: text, Err: derr.Err}
+	}
+	return msg, text, err
+}
+
+// RunBoardCommand chooses our move, if it's our turn, and sends it. If the
+// move is to resign, the game is lost.
+func (svc *Service) RunBoardCommand(bc *command.BoardCommand) error {
+	// A board can start a rematch, for which we don't know our color yet.
+	if err := svc.beginGame(); err != nil {
+		return err
+	}
+	state, err := game.ParseState(svc.botColor, bc.Board, bc.Dead)
+	if err != nil {
+		return &ProtocolError{Stage: "decode", Kind: "board", Err: err}
+	}
+	unchanged := svc.awaiting && state.Board == svc.state.Board
+	if svc.awaiting && !unchanged {
+		svc.Etiquette.Accepted()
+		svc.awaiting, svc.tried = false, nil
+	}
+	if !svc.ourTurn(bc) {
+		// Likely the server showing us our own move; pondering goes on.
+		svc.Log.Debug("not our turn", "turn", bc.TurnColor)
+		return nil
+	}
+	svc.stopPondering()
+	if unchanged {
+		// The server has sent back the board we moved in, for us to move
+		// again: it didn't accept our move.
+		svc.pondering = nil
+		return svc.moveRejected("the board is unchanged")
+	}
+	if svc.pondering != nil {
+		if reply, ok := identifyReply(svc.pondering, bc.LastMove); ok {
+			svc.Log.Debug("opponent replied", "move", reply.String())
+			if aware, ok := svc.bot.(ReplyAware); ok {
+				aware.OpponentReplied(svc.pondering, reply)
+			}
+		}
+		svc.pondering = nil
+	}
+	svc.observe(bc)
+	if aware, ok := svc.bot.(OpponentAware); ok {
+		aware.SetOpponent(svc.profile)
+	}
+	start := time.Now()
+	svc.why = ""
+	mv := svc.chooseMove(&state)
+	moveSeconds.Observe(time.Since(start).Seconds(), svc.bot.Name())
+	svc.state, svc.score = &state, svc.evaluate(&state)
+	if svc.why == "" {
+		svc.why = svc.explain()
+	}
+	offer := false
+	if mv.Action() != move.Quit && svc.Etiquette.Resign(svc.score) {
+		svc.Log.Info("resigning", "score", svc.score)
+		mv, svc.why = move.NewQuit(), "I'm too far behind to go on"
+	} else if mv.Action() != move.Quit {
+		offer = svc.Etiquette.OfferDraw(&state)
+	}
+	if err := svc.play(mv); err != nil || !offer {
+		return err
+	}
+	// As in chess, we offer a draw after moving. That way, should the
+	// server refuse the offer, we won't take it for refusing the move.
+	svc.Log.Info("offering a draw")
+	return svc.send(command.DrawCommand{Action: "offerdraw", Color: svc.botColor})
+}
+
+// ourTurn says whether bc is for us to move in, going by its TurnColor
+// once we know our color, or else by its YourTurn.
+func (svc *Service) ourTurn(bc *command.BoardCommand) bool {
+	if bc.TurnColor != "" && svc.botColor != "" {
+		return strings.EqualFold(bc.TurnColor, svc.botColor)
+	}
+	return bc.YourTurn
+}
+
+// play sends our move in svc.state. If the move is to resign, the game is
+// lost, though it isn't over until the server says so or a rematch begins.
+func (svc *Service) play(mv move.T) error {
+	svc.Log.Debug("sending move", "move", mv.String())
+	if err := svc.SendCommand(mv.Command()); err != nil {
+		return err
+	}
+	svc.lastMove, svc.awaiting, svc.after = mv, true, nil
+	svc.updateStatus(func(s *Status) { s.Moves++ })
+	switch mv.Action() {
+	case move.Move, move.Take:
+		after := mv.Apply(svc.state)
+		svc.after = &after
+		svc.startPondering(after)
+	case move.Quit:
+		svc.result = "loss"
+	}
+	return nil
+}
+
+// moveRejected answers the server's rejection of our last move with the
+// next-best move, or resigns once the etiquette says we've tried enough.
+func (svc *Service) moveRejected(reason string) error {
+	svc.rejected++
+	moveRejections.Inc(svc.bot.Name())
+	svc.updateStatus(func(s *Status) { s.Rejections++ })
+	svc.tried = append(svc.tried, svc.lastMove)
+	svc.Log.Warn("move rejected", "move", svc.lastMove.String(), "reason", reason,
+		"rejections", svc.rejected)
+	mv := move.NewQuit()
+	if !svc.Etiquette.Rejected() {
+		mv = svc.nextBest()
+	}
+	svc.why = "the server wouldn't accept my first choice"
+	if mv.Action() == move.Quit {
+		svc.Log.Info("resigning after rejected moves", "tried", len(svc.tried))
+	}
+	return svc.play(mv)
+}
+
+// nextBest is the best move in svc.state that the server hasn't rejected,
+// or a resignation if it has rejected them all.
+func (svc *Service) nextBest() move.T {
+	ranker, ok := svc.bot.(Ranker)
+	if !ok {
+		ranker = bot.NewGreedyBot()
+	}
+	for _, m := range ranker.RankMoves(svc.state) {
+		tried := false
+		for _, t := range svc.tried {
+			tried = tried || m == t
+		}
+		if !tried {
+			return m
+		}
+	}
+	return move.NewQuit()
+}
+
+// chooseMove asks the bot for a move. Should the bot panic, it plays a
+// random legal move instead: a poor move is better than forfeiting.
+func (svc *Service) chooseMove(state *game.State) (mv move.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			botFailures.Inc(svc.bot.Name())
+			svc.Log.Error("bot failed; playing a random move", "bot", svc.bot.Name(),
+				"reason", r, "stack", string(debug.Stack()))
+			mv, svc.why = svc.randomMove(state), "I got confused, and picked one at random"
+		}
+	}()
+	return svc.bot.ChooseMove(state)
+}
 
-	if _, bytes, err := svc.conn.ReadMessage(); err != nil {
-		panic(fmt.Sprintf("websocket read error (%v)", err.Error()))
-	} else if err = json.Unmarshal(bytes, &paoCommand); err != nil {
-		panic(fmt.Sprintf("command decode error: %v (input: %v)", err, bytes))
+// randomMove chooses any legal move, or resigns if there are none.
+func (svc *Service) randomMove(state *game.State) move.T {
+	var moves []move.T
+	if state.Us == nil { // the first move: any flip will do
+		for r, row := range state.Board {
+			for c, piece := range row {
+				if piece == game.FaceDown {
+					moves = append(moves, move.NewFlip(r, c))
+				}
+			}
+		}
 	} else {
-		return paoCommand.Action, bytes
+		moves = move.LegalMoves(state.Us, state.Them, state.Board)
 	}
+	if len(moves) == 0 {
+		return move.NewQuit()
+	}
+	return moves[svc.rand.Intn(len(moves))]
+}
+
+// startPondering lets the bot, if it knows how, search the opponent's
+// likely replies to our move while we wait for the next board.
+func (svc *Service) startPondering(next game.State) {
+	ponderer, ok := svc.bot.(Ponderer)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	svc.pondering, svc.ponderEnd, svc.ponderRun = &next, cancel, done
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				svc.Log.Warn("pondering failed", "reason", r)
+			}
+		}()
+		ponderer.Ponder(ctx, &next)
+	}()
 }
 
-func (svc *Service) RunBoardCommand(text []byte) bool {
-	var bc command.BoardCommand
-	if err := json.Unmarshal(text, &bc); err != nil {
-		panic(fmt.Sprintf("board decode error: %v (input: %v)", err, text))
+// stopPondering cancels any pondering in progress, and waits for it to
+// finish so that the bot can be used again.
+func (svc *Service) stopPondering() {
+	if svc.ponderEnd == nil {
+		return
 	}
-	state := game.NewState(svc.botColor, bc.Board, bc.Dead)
-	mv := svc.bot.ChooseMove(&state)
-	fmt.Printf("Sending move: %s\n", mv.String())
-	svc.SendCommand(mv.Command())
-	return mv.Action() != move.Quit
+	svc.ponderEnd()
+	<-svc.ponderRun
+	svc.ponderEnd, svc.ponderRun = nil, nil
 }
 
-func (svc *Service) RunColorCommand(text []byte) {
-	var bc command.ColorCommand
-	if err := json.Unmarshal(text, &bc); err != nil {
-		panic(fmt.Sprintf("color decode error: %v (input: %v)", err, text))
+// identifyReply finds which of the opponent's legal moves in state is
+// described by a board command's LastMove: the square moved from and the
+// square moved to, either as two strings or as one like "B3>B4" or "?B3".
+func identifyReply(state *game.State, lastMove []string) (move.T, bool) {
+	desc := strings.ToUpper(strings.Join(lastMove, ">"))
+	if desc == "" || state.Us == nil {
+		return move.NewQuit(), false
+	}
+	for _, m := range move.LegalMoves(state.Us, state.Them, state.Board) {
+		var want string
+		if m.Action() == move.Flip {
+			want = m.From().String()
+		} else {
+			want = m.From().String() + ">" + m.To().String()
+		}
+		if desc == want || desc == "?"+want {
+			return m, true
+		}
+	}
+	return move.NewQuit(), false
+}
+
+func (svc *Service) RunColorCommand(bc *command.ColorCommand) error {
+	if c := strings.ToLower(bc.Color); c != "red" && c != "black" {
+		return &ProtocolError{Stage: "decode", Kind: "color",
+			Err: fmt.Errorf("unknown color %q", bc.Color)}
+	}
+	if err := svc.beginGame(); err != nil {
+		return err
 	}
 	svc.botColor = bc.Color
+	svc.updateStatus(func(s *Status) { s.Color = bc.Color })
+	svc.Log.Info("color assigned", "color", bc.Color)
+	return nil
+}
+
+// observe adds the opponent's latest move, described by bc, to what we
+// know of them. Only a board for our turn describes their move; the others
+// show our own.
+func (svc *Service) observe(bc *command.BoardCommand) {
+	if svc.botColor == "" {
+		return
+	}
+	o, ok := opponent.Observe(bc, otherColor(svc.botColor))
+	if !ok {
+		return
+	}
+	svc.observed.Add(o)
+	svc.profile.Add(o)
+	svc.identifyOpponent()
+}
+
+// RunChatCommand learns players' names from their chat messages, and
+// answers chat commands like "!hint".
+func (svc *Service) RunChatCommand(cc *command.ChatCommand) error {
+	if cc.Player != "" && cc.Color != "" {
+		svc.players[strings.ToLower(cc.Color)] = cc.Player
+		svc.identifyOpponent()
+	}
+	if strings.HasPrefix(strings.TrimSpace(cc.Message), "!") {
+		return svc.chatCommand(cc)
+	}
+	return nil
 }
 
-func (svc *Service) SendCommand(c command.Command) {
-	if err := svc.conn.WriteJSON(c); err != nil {
-		panic(fmt.Sprintf("websocket write error: %s (output: %v)", err, c))
+// identifyOpponent looks up the opponent's profile, once we know both our
+// color and their name.
+func (svc *Service) identifyOpponent() {
+	if svc.opponentName != "" || svc.botColor == "" {
+		return
+	}
+	name := svc.players[strings.ToLower(otherColor(svc.botColor))]
+	if name == "" {
+		return
+	}
+	svc.opponentName = name
+	if svc.Opponents != nil {
+		svc.profile = svc.Opponents.Get(name)
+		svc.profile.Add(svc.observed)
+	}
+	svc.Log.Info("opponent identified", "name", name, "games", svc.profile.Games)
+}
+
+// rememberOpponent saves what we've learned of the opponent this game.
+func (svc *Service) rememberOpponent() {
+	if svc.Opponents == nil || svc.opponentName == "" || svc.observed.Moves == 0 {
+		return
+	}
+	svc.observed.Games = 1
+	if err := svc.Opponents.Add(svc.opponentName, svc.observed); err != nil {
+		svc.Log.Warn("can't save opponent profile", "name", svc.opponentName, "err", err)
+	}
+}
+
+func otherColor(color string) string {
+	if strings.ToLower(color) == "red" {
+		return "Black"
+	}
+	return "Red"
+}
+
+// evaluate is our evaluation of state, in which we just chose a move: the
+// bot's own search score if it has one, or else the materiel.
+func (svc *Service) evaluate(state *game.State) int {
+	if scorer, ok := svc.bot.(interface{ LastScore() int }); ok {
+		return scorer.LastScore()
+	}
+	return search.Material{}.Evaluate(state)
+}
+
+// RunErrorCommand notes the server's refusal of a command. If it refused
+// our move, we try another. An error only refers to our move if the move
+// was the last thing we sent; otherwise it might refuse a draw offer or a
+// chat message.
+func (svc *Service) RunErrorCommand(ec *command.ErrorCommand) error {
+	if !svc.awaiting || svc.lastSent != "move" {
+		svc.Log.Warn("server error", "message", ec.Message, "after", svc.lastSent)
+		return nil
+	}
+	svc.stopPondering()
+	svc.pondering = nil
+	return svc.moveRejected(ec.Message)
+}
+
+// RunGameOverCommand notes how the game ended, and gets ready for a
+// rematch.
+func (svc *Service) RunGameOverCommand(gc *command.GameOverCommand) error {
+	if !svc.playing {
+		svc.Log.Debug("ignoring gameover between games", "message", gc.Message)
+		return nil
+	}
+	switch {
+	case svc.drawn || strings.Contains(strings.ToLower(gc.Message), "draw"):
+		svc.result = "draw"
+	case gc.YouWin:
+		svc.result = "win"
+	default:
+		svc.result = "loss"
+	}
+	svc.Log.Info("game over", "result", svc.result, "message", gc.Message)
+	svc.endGame()
+	return svc.say("gg")
+}
+
+// RunDrawCommand answers the opponent's draw offer, or notes their answer
+// to ours.
+func (svc *Service) RunDrawCommand(dc *command.DrawCommand) error {
+	switch dc.Action {
+	case "offerdraw":
+		answer := "declinedraw"
+		if svc.state != nil && svc.Etiquette.AcceptDraw(svc.state, svc.score) {
+			answer = "acceptdraw"
+		}
+		svc.drawn = answer == "acceptdraw"
+		svc.Log.Info("draw offered", "by", dc.Color, "answer", answer)
+		return svc.send(command.DrawCommand{Action: answer, Color: svc.botColor})
+	case "acceptdraw":
+		svc.drawn = true
+		svc.Log.Info("draw accepted", "by", dc.Color)
+	case "declinedraw":
+		svc.Log.Info("draw declined", "by", dc.Color)
+	}
+	return nil
+}
+
+func (svc *Service) SendCommand(c command.Command) error {
+	return svc.send(c)
+}
+
+// outgoing encodes our messages, strictly: sending one the server won't
+// recognize is a bug.
+var outgoing = command.Codec{Registry: command.ClientMessages, Strict: true}
+
+// send sends a message, returning a *ProtocolError if it can't.
+func (svc *Service) send(msg interface{}) error {
+	data, err := outgoing.Encode(msg)
+	if err != nil {
+		return &ProtocolError{Stage: "write", Err: err}
+	}
+	svc.writeMu.Lock()
+	svc.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	err = svc.conn.WriteMessage(websocket.TextMessage, data)
+	svc.writeMu.Unlock()
+	if err != nil {
+		return &ProtocolError{Stage: "write", Err: err}
 	}
+	// Encode has made sure that msg has an Action.
+	svc.lastSent = reflect.Indirect(reflect.ValueOf(msg)).FieldByName("Action").String()
+	return nil
 }
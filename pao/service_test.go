@@ -0,0 +1,682 @@
+package pao
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/perlmonger42/greedy-bot/bot"
+	"github.com/perlmonger42/greedy-bot/command"
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/logging"
+	"github.com/perlmonger42/greedy-bot/move"
+	"github.com/perlmonger42/greedy-bot/opponent"
+)
+
+// testBot plays the first move it finds, or resigns if there are none,
+// and records what the service asks of it.
+type testBot struct {
+	name   string
+	mu     sync.Mutex
+	seeds  []int64  // the seeds it was given, one per game
+	colors []string // the side to move in each position; "" before the first flip
+}
+
+func (b *testBot) Name() string {
+	return b.name
+}
+
+func (b *testBot) ChooseMove(gs *game.State) move.T {
+	color := ""
+	if gs.Us != nil {
+		color = gs.Us.Color()
+	}
+	b.mu.Lock()
+	b.colors = append(b.colors, color)
+	b.mu.Unlock()
+	if moves := b.RankMoves(gs); len(moves) > 0 {
+		return moves[0]
+	}
+	return move.NewQuit()
+}
+
+// RankMoves lists the flips in board order before the first flip, and
+// otherwise the legal moves in the order LegalMoves finds them.
+func (b *testBot) RankMoves(gs *game.State) []move.T {
+	if gs.Us != nil {
+		return move.LegalMoves(gs.Us, gs.Them, gs.Board)
+	}
+	var flips []move.T
+	for r, row := range gs.Board {
+		for c, p := range row {
+			if p == game.FaceDown {
+				flips = append(flips, move.NewFlip(r, c))
+			}
+		}
+	}
+	return flips
+}
+
+func (b *testBot) Reseed(seed int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seeds = append(b.seeds, seed)
+}
+
+func (b *testBot) record() (seeds []int64, colors []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]int64{}, b.seeds...), append([]string{}, b.colors...)
+}
+
+// newService builds a quiet service that plays b, with seed 100.
+func newService(b Bot) *Service {
+	svc := NewServiceFor(b, 100)
+	svc.Log = logging.New(ioutil.Discard)
+	return svc
+}
+
+// fakePao plays the part of the Pao server, talking to a Service over a
+// real websocket connection.
+type fakePao struct {
+	t      *testing.T
+	server *httptest.Server
+	conn   *websocket.Conn
+	done   chan struct{} // closed once the service's Run returns
+}
+
+// dial runs svc on a new connection from a fakePao, which is closed when
+// the test ends.
+func dial(t *testing.T, svc *Service) *fakePao {
+	t.Helper()
+	p := &fakePao{t: t, done: make(chan struct{})}
+	upgrader := websocket.Upgrader{}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(p.done)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		svc.Run(conn)
+	}))
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(p.server.URL, "http"), nil)
+	if err != nil {
+		p.server.Close()
+		t.Fatal(err)
+	}
+	p.conn = conn
+	t.Cleanup(p.close)
+	return p
+}
+
+// send sends the bot a message.
+func (p *fakePao) send(msg interface{}) {
+	p.t.Helper()
+	p.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	if err := p.conn.WriteJSON(msg); err != nil {
+		p.t.Fatalf("sending %+v: %v", msg, err)
+	}
+}
+
+// next reads the bot's next message.
+func (p *fakePao) next() interface{} {
+	p.t.Helper()
+	p.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := p.conn.ReadMessage()
+	if err != nil {
+		p.t.Fatalf("reading from the bot: %v", err)
+	}
+	msg, err := outgoing.Decode(data)
+	if err != nil {
+		p.t.Fatalf("the bot sent %s: %v", data, err)
+	}
+	return msg
+}
+
+// expect reads the bot's messages until one with the given action, passing
+// over chat, and returns its Argument.
+func (p *fakePao) expect(action string) string {
+	p.t.Helper()
+	for {
+		switch msg := p.next().(type) {
+		case *command.Command:
+			if msg.Action == action {
+				return msg.Argument
+			} else if msg.Action != "chat" {
+				p.t.Fatalf("the bot sent %s %q; want %s", msg.Action, msg.Argument, action)
+			}
+		case *command.DrawCommand:
+			if msg.Action == action {
+				return msg.Color
+			}
+			p.t.Fatalf("the bot sent %s; want %s", msg.Action, action)
+		}
+	}
+}
+
+// expectNoMove checks that the bot sends nothing but chat before it
+// answers a !help, which costs one of the session's chat commands.
+func (p *fakePao) expectNoMove() {
+	p.t.Helper()
+	p.send(command.ChatCommand{Action: "chat", Player: "alice", Message: "!help"})
+	for {
+		if strings.Contains(p.expect("chat"), "!eval") {
+			return
+		}
+	}
+}
+
+// wait waits for the session to end.
+func (p *fakePao) wait() {
+	p.t.Helper()
+	select {
+	case <-p.done:
+	case <-time.After(10 * time.Second):
+		p.t.Fatalf("the session didn't end")
+	}
+}
+
+// close says goodbye with a close frame, and waits for the session to end.
+func (p *fakePao) close() {
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	p.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	p.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := p.conn.NextReader(); err != nil {
+			break
+		}
+	}
+	p.conn.Close()
+	p.wait()
+	p.server.Close()
+}
+
+// board turns rows like "p???????" into a board command's Board.
+func board(rows ...string) [][]string {
+	b := [][]string{}
+	for _, row := range rows {
+		r := []string{}
+		for _, c := range row {
+			r = append(r, string(c))
+		}
+		b = append(b, r)
+	}
+	return b
+}
+
+var faceDown = board("????????", "????????", "????????", "????????")
+
+// revealed is a board for red to move in, with every piece face up: those
+// in rows are on the board, and the rest are dead.
+func revealed(rows ...string) command.BoardCommand {
+	left := map[string]int{
+		"q": 2, "p": 5, "h": 2, "c": 2, "e": 2, "g": 2, "k": 1,
+		"Q": 2, "P": 5, "H": 2, "C": 2, "E": 2, "G": 2, "K": 1,
+	}
+	b := board(rows...)
+	for _, row := range b {
+		for _, p := range row {
+			left[p]--
+		}
+	}
+	dead := []string{}
+	for p, n := range left {
+		for ; n > 0; n-- {
+			dead = append(dead, p)
+		}
+	}
+	return command.BoardCommand{Action: "board", Board: b, Dead: dead, YourTurn: true, TurnColor: "Red"}
+}
+
+func TestRematch(t *testing.T) {
+	b := &testBot{name: "Test"}
+	svc := newService(b)
+	wins, losses := gamesFinished.Get("win"), gamesFinished.Get("loss")
+	p := dial(t, svc)
+
+	p.send(command.BoardCommand{Action: "board", Board: faceDown, YourTurn: true})
+	if mv := p.expect("move"); mv != "?A1" {
+		t.Errorf("first move %q, want ?A1", mv)
+	}
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+	p.send(command.BoardCommand{Action: "board", Board: board("pP??????", "????????", "????????", "????????"),
+		LastMove: []string{"?B1"}, YourTurn: true, TurnColor: "Red"})
+	p.expect("move")
+	p.send(command.GameOverCommand{Action: "gameover", Message: "Red wins", YouWin: true})
+	if gg := p.expect("chat"); gg != "gg" {
+		t.Errorf("said %q after the game, want gg", gg)
+	}
+	if s := svc.Status(); s.Games != 1 || s.Color != "" || s.Moves != 0 || s.Rejections != 0 {
+		t.Errorf("status after the first game: %+v", s)
+	}
+	if n := gamesFinished.Get("win") - wins; n != 1 {
+		t.Errorf("recorded %v wins, want 1", n)
+	}
+
+	p.send(command.ColorCommand{Action: "color", Color: "Black"})
+	if hi := p.expect("chat"); !strings.Contains(hi, "Rematch") {
+		t.Errorf("greeted the rematch with %q", hi)
+	}
+	p.send(command.BoardCommand{Action: "board", Board: board("p???????", "????????", "????????", "????????"),
+		LastMove: []string{"?A1"}, YourTurn: true, TurnColor: "Black"})
+	if mv := p.expect("move"); mv != "?B1" {
+		t.Errorf("first move of the rematch %q, want ?B1", mv)
+	}
+	if s := svc.Status(); s.Games != 1 || s.Color != "Black" {
+		t.Errorf("status during the rematch: %+v", s)
+	}
+	p.send(command.GameOverCommand{Action: "gameover", Message: "Red wins"})
+	if gg := p.expect("chat"); gg != "gg" {
+		t.Errorf("said %q after the rematch, want gg", gg)
+	}
+	if n := gamesFinished.Get("loss") - losses; n != 1 {
+		t.Errorf("recorded %v losses, want 1", n)
+	}
+	p.close()
+
+	seeds, colors := b.record()
+	if len(seeds) != 2 || seeds[0] != 100 || seeds[1] != 101 {
+		t.Errorf("the games were seeded with %v, want [100 101]", seeds)
+	}
+	if want := []string{"", "Red", "Black"}; strings.Join(colors, ",") != strings.Join(want, ",") {
+		t.Errorf("the bot moved for %q, want %q", colors, want)
+	}
+	if s := svc.Status(); s.Games != 2 {
+		t.Errorf("finished %d games, want 2", s.Games)
+	}
+}
+
+// TestRematchAfterResign checks that a board straight after we resign
+// starts a new game, in which we don't know our color yet.
+func TestRematchAfterResign(t *testing.T) {
+	b := &testBot{name: "Test"}
+	losses := gamesFinished.Get("loss")
+	p := dial(t, newService(b))
+
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+	p.send(command.BoardCommand{Action: "board", Board: board("K.......", "........", "........", "........"),
+		YourTurn: true, TurnColor: "Red"})
+	p.expect("resign")
+	p.send(command.BoardCommand{Action: "board", Board: faceDown, YourTurn: true})
+	if mv := p.expect("move"); mv != "?A1" {
+		t.Errorf("first move of the rematch %q, want ?A1", mv)
+	}
+	if n := gamesFinished.Get("loss") - losses; n != 1 {
+		t.Errorf("recorded %v losses, want the resigned game", n)
+	}
+	p.close()
+
+	seeds, colors := b.record()
+	if len(seeds) != 2 || seeds[1] != 101 {
+		t.Errorf("the games were seeded with %v, want [100 101]", seeds)
+	}
+	if want := []string{"Red", ""}; strings.Join(colors, ",") != strings.Join(want, ",") {
+		t.Errorf("the bot moved for %q, want %q", colors, want)
+	}
+}
+
+// TestRematchForgetsObservations checks that what we saw of an opponent we
+// never learned the name of isn't credited to the next one.
+func TestRematchForgetsObservations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pao")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := opponent.Open(filepath.Join(dir, "opponents.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := newService(&testBot{name: "Test"})
+	svc.Opponents = store
+	p := dial(t, svc)
+
+	blackFlipped := command.BoardCommand{Action: "board", Board: board("P???????", "????????", "????????", "????????"),
+		LastMove: []string{"?A1"}, YourTurn: true, TurnColor: "Red"}
+	for game := 0; game < 2; game++ {
+		p.send(command.ColorCommand{Action: "color", Color: "Red"})
+		if game == 1 {
+			p.send(command.ChatCommand{Action: "chat", Player: "bob", Color: "Black", Message: "hi"})
+		}
+		p.send(blackFlipped)
+		p.expect("move")
+		p.send(command.GameOverCommand{Action: "gameover", Message: "Black wins"})
+		p.expect("chat")
+	}
+	p.close()
+
+	if bob := store.Get("bob"); bob.Games != 1 || bob.Moves != 1 || bob.Flips != 1 {
+		t.Errorf("bob's profile is %+v, want the one flip he made", bob)
+	}
+}
+
+// cartToMove has four moves for red's cart, none of them a capture.
+var cartToMove = revealed("........", "...c....", "........", ".......K")
+
+func TestMoveRejected(t *testing.T) {
+	svc := newService(&testBot{name: "Test"})
+	p := dial(t, svc)
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+
+	p.send(cartToMove)
+	moves := []string{p.expect("move")}
+	p.send(cartToMove) // the board we moved in, so the move was rejected
+	moves = append(moves, p.expect("move"))
+	p.send(command.ErrorCommand{Action: "error", Message: "illegal move"})
+	moves = append(moves, p.expect("move"))
+	for i := range moves {
+		for j := 0; j < i; j++ {
+			if moves[i] == moves[j] {
+				t.Errorf("move %d, %s, was already rejected", i, moves[i])
+			}
+		}
+	}
+	p.send(command.ErrorCommand{Action: "error", Message: "illegal move"})
+	p.expect("resign")
+	if s := svc.Status(); s.Rejections != 3 {
+		t.Errorf("counted %d rejections, want 3", s.Rejections)
+	}
+}
+
+func TestAcceptedMoveResetsRejections(t *testing.T) {
+	svc := newService(&testBot{name: "Test"})
+	svc.Etiquette = bot.Etiquette{RejectLimit: 2}
+	p := dial(t, svc)
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+
+	p.send(cartToMove)
+	p.expect("move")
+	p.send(command.ErrorCommand{Action: "error", Message: "illegal move"})
+	p.expect("move")
+	// A new board accepts the move, and restarts the count.
+	p.send(revealed("........", "...c....", "........", "......K."))
+	p.expect("move")
+	p.send(command.ErrorCommand{Action: "error", Message: "illegal move"})
+	p.expect("move")
+	p.send(command.ErrorCommand{Action: "error", Message: "illegal move"})
+	p.expect("resign")
+	if s := svc.Status(); s.Rejections != 3 {
+		t.Errorf("counted %d rejections, want 3", s.Rejections)
+	}
+}
+
+// TestNotRejections checks that the bot doesn't take the board it moved in
+// for a rejection when it isn't its turn, nor an error after its draw offer.
+func TestNotRejections(t *testing.T) {
+	svc := newService(&testBot{name: "Test"})
+	svc.Etiquette = bot.Etiquette{OfferDraws: true, RejectLimit: 3}
+	p := dial(t, svc)
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+
+	p.send(cartToMove)
+	p.expect("move")
+	theirTurn := cartToMove
+	theirTurn.YourTurn, theirTurn.TurnColor = false, "Black"
+	p.send(theirTurn)
+	p.expectNoMove()
+
+	// A cannon and a pawn can never take each other.
+	p.send(revealed("q.P.....", "........", "........", "........"))
+	p.expect("move")
+	if color := p.expect("offerdraw"); color != "Red" {
+		t.Errorf("offered a draw as %q", color)
+	}
+	p.send(command.ErrorCommand{Action: "error", Message: "draws aren't allowed"})
+	p.expectNoMove()
+	if s := svc.Status(); s.Rejections != 0 {
+		t.Errorf("counted %d rejections, want none", s.Rejections)
+	}
+}
+
+// TestNoMoveOutOfTurn checks that the bot doesn't answer the board showing
+// its own move, with the opponent to move.
+func TestNoMoveOutOfTurn(t *testing.T) {
+	svc := newService(&testBot{name: "Test"})
+	p := dial(t, svc)
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+
+	p.send(cartToMove)
+	p.expect("move")
+	moved := revealed("........", "..c.....", "........", ".......K")
+	moved.LastMove, moved.YourTurn, moved.TurnColor = []string{"D2", "C2"}, false, "Black"
+	p.send(moved)
+	p.expectNoMove()
+	replied := revealed("........", "..c.....", "........", "......K.")
+	replied.LastMove = []string{"H4", "G4"}
+	p.send(replied)
+	p.expect("move")
+	if s := svc.Status(); s.Rejections != 0 {
+		t.Errorf("counted %d rejections, want none", s.Rejections)
+	}
+}
+
+func TestMalformedBoard(t *testing.T) {
+	bad := command.BoardCommand{Action: "board", Board: board("????????", "????????"), YourTurn: true}
+	for _, policy := range []Policy{LogMalformed, IgnoreMalformed, AbortOnMalformed} {
+		t.Run(policy.String(), func(t *testing.T) {
+			shorten(t, &closeTimeout, 200*time.Millisecond)
+			decodes := protocolErrors.Get("decode")
+			svc := newService(&testBot{name: "Test"})
+			svc.Malformed = policy
+			p := dial(t, svc)
+			p.send(bad)
+			if policy == AbortOnMalformed {
+				p.wait()
+			} else {
+				p.send(command.ChatCommand{Action: "chat", Player: "alice", Message: "!help"})
+				complained := false
+				for reply := ""; !strings.Contains(reply, "!eval"); reply = p.expect("chat") {
+					complained = complained || strings.Contains(reply, "couldn't make sense of that board")
+				}
+				if complained != (policy == LogMalformed) {
+					t.Errorf("complained in the chat: %v", complained)
+				}
+				p.send(command.BoardCommand{Action: "board", Board: faceDown, YourTurn: true})
+				p.expect("move")
+			}
+			if n := protocolErrors.Get("decode") - decodes; n != 1 {
+				t.Errorf("counted %v malformed messages, want 1", n)
+			}
+		})
+	}
+}
+
+// panicBot panics instead of choosing a move.
+type panicBot struct{}
+
+func (panicBot) Name() string {
+	return "Panicky"
+}
+
+func (panicBot) ChooseMove(gs *game.State) move.T {
+	panic("out of ideas")
+}
+
+func TestBotPanics(t *testing.T) {
+	failures := botFailures.Get("Panicky")
+	p := dial(t, newService(panicBot{}))
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+	p.send(cartToMove)
+	got := p.expect("move")
+	gs := game.NewState("Red", cartToMove.Board, cartToMove.Dead)
+	legal := false
+	for _, m := range move.LegalMoves(gs.Us, gs.Them, gs.Board) {
+		legal = legal || m.Command().Argument == got
+	}
+	if !legal {
+		t.Errorf("played %q, which isn't a legal move", got)
+	}
+	if n := botFailures.Get("Panicky") - failures; n != 1 {
+		t.Errorf("counted %v bot failures, want 1", n)
+	}
+	if why := p.chat("!explain"); !strings.HasSuffix(why, "because I got confused, and picked one at random.") {
+		t.Errorf("explained the move with %q", why)
+	}
+}
+
+// shorten sets one of the connection's timeouts for the rest of the test.
+func shorten(t *testing.T, timeout *time.Duration, d time.Duration) {
+	old := *timeout
+	*timeout = d
+	t.Cleanup(func() { *timeout = old })
+}
+
+func TestKeepAlive(t *testing.T) {
+	shorten(t, &pongWait, 200*time.Millisecond)
+	shorten(t, &pingPeriod, 50*time.Millisecond)
+	timeouts := protocolErrors.Get("timeout")
+	p := dial(t, newService(&testBot{name: "Test"}))
+
+	// Reading answers the bot's pings, though it has nothing to say.
+	p.conn.SetReadDeadline(time.Now().Add(4 * pongWait))
+	if _, _, err := p.conn.ReadMessage(); err == nil {
+		t.Fatalf("the bot said something unprompted")
+	}
+	select {
+	case <-p.done:
+		t.Fatalf("the session ended, though its pings were answered")
+	default:
+	}
+
+	// Now that we've stopped reading, the pings go unanswered.
+	p.wait()
+	if n := protocolErrors.Get("timeout") - timeouts; n != 1 {
+		t.Errorf("counted %v timeouts, want 1", n)
+	}
+}
+
+func TestMessageTooBig(t *testing.T) {
+	sizes := protocolErrors.Get("size")
+	p := dial(t, newService(&testBot{name: "Test"}))
+	p.send(command.ChatCommand{Action: "chat", Message: strings.Repeat("gg ", maxMessageSize/3+1)})
+	p.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := p.conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("got %v, want the bot to close the connection as the message is too big", err)
+	}
+	p.wait()
+	if n := protocolErrors.Get("size") - sizes; n != 1 {
+		t.Errorf("counted %v oversized messages, want 1", n)
+	}
+}
+
+// TestCloseTimeout checks that a session ends even if the other side
+// never answers its close frame.
+func TestCloseTimeout(t *testing.T) {
+	shorten(t, &closeTimeout, 200*time.Millisecond)
+	svc := newService(&testBot{name: "Test"})
+	svc.Malformed = AbortOnMalformed
+	p := dial(t, svc)
+	start := time.Now()
+	p.conn.WriteMessage(websocket.TextMessage, []byte(`{"Action": `))
+	p.wait()
+	if elapsed := time.Since(start); elapsed < closeTimeout || elapsed > pongWait/2 {
+		t.Errorf("the session took %v to end, want about %v", elapsed, closeTimeout)
+	}
+}
+
+// TestResign checks that a server shutting down resigns a game in
+// progress, even one whose colors haven't been dealt yet, and just says
+// goodbye to a session between games.
+func TestResign(t *testing.T) {
+	t.Run("playing", func(t *testing.T) {
+		losses := gamesFinished.Get("loss")
+		svc := newService(&testBot{name: "Test"})
+		p := dial(t, svc)
+		p.send(command.BoardCommand{Action: "board", Board: faceDown, YourTurn: true})
+		p.expect("move")
+		if !svc.Status().Playing {
+			t.Errorf("the status says no game is in progress")
+		}
+		svc.Resign()
+		p.expect("resign")
+		if _, _, err := p.conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Errorf("got %v, want the bot to close the connection as it's going away", err)
+		}
+		p.wait()
+		if n := gamesFinished.Get("loss") - losses; n != 1 {
+			t.Errorf("recorded %v losses, want the resigned game", n)
+		}
+	})
+	t.Run("idle", func(t *testing.T) {
+		svc := newService(&testBot{name: "Test"})
+		p := dial(t, svc)
+		if svc.Status().Playing {
+			t.Errorf("the status says a game is in progress")
+		}
+		svc.Resign()
+		if _, _, err := p.conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Errorf("got %v, want the bot to close the connection without resigning", err)
+		}
+		p.wait()
+	})
+}
+
+// TestOwnMovesNotObserved checks that the board showing our own flip isn't
+// taken for the opponent's.
+func TestOwnMovesNotObserved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pao")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := opponent.Open(filepath.Join(dir, "opponents.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := newService(&testBot{name: "Test"})
+	svc.Opponents = store
+	p := dial(t, svc)
+
+	p.send(command.ColorCommand{Action: "color", Color: "Red"})
+	p.send(command.ChatCommand{Action: "chat", Player: "bob", Color: "Black", Message: "hi"})
+	p.send(command.BoardCommand{Action: "board", Board: faceDown, YourTurn: true, TurnColor: "Red"})
+	if mv := p.expect("move"); mv != "?A1" {
+		t.Fatalf("flipped %q, want ?A1", mv)
+	}
+	p.send(command.BoardCommand{Action: "board", Board: board("p???????", "????????", "????????", "????????"),
+		LastMove: []string{"?A1"}, TurnColor: "Black"})
+	p.send(command.BoardCommand{Action: "board", Board: board("pP??????", "????????", "????????", "????????"),
+		LastMove: []string{"?B1"}, YourTurn: true, TurnColor: "Red"})
+	p.expect("move")
+	p.send(command.GameOverCommand{Action: "gameover", Message: "Black wins"})
+	p.expect("chat")
+	p.close()
+
+	if bob := store.Get("bob"); bob.Moves != 1 || bob.Flips != 1 {
+		t.Errorf("bob's profile is %+v, want only the one flip he made", bob)
+	}
+}
+
+func TestIdentifyReply(t *testing.T) {
+	state := game.NewState("Black", [][]string{
+		{"p", ".", "G", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "?"},
+	}, []string{})
+	for _, test := range []struct {
+		lastMove []string
+		want     string // the move as we'd send it, or "" if it shouldn't be found
+	}{
+		{[]string{"C1", "D1"}, "C1>D1"},
+		{[]string{"c1>c2"}, "C1>C2"},
+		{[]string{"?H4"}, "?H4"},
+		{[]string{"H4"}, "?H4"},
+		{[]string{"A1", "A2"}, ""}, // red's pawn
+		{[]string{"C1", "E1"}, ""}, // too far
+		{nil, ""},
+	} {
+		m, ok := identifyReply(&state, test.lastMove)
+		if got := m.Command().Argument; ok != (test.want != "") || ok && got != test.want {
+			t.Errorf("identifyReply(%q) = %q, %v; want %q", test.lastMove, got, ok, test.want)
+		}
+	}
+}
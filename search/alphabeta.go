@@ -0,0 +1,321 @@
+package search
+
+import (
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// MaxPly is the deepest any search will look.
+const MaxPly = 64
+
+// FlipPolicy says how a negamax search values a flip, whose outcome is
+// left to chance.
+type FlipPolicy int
+
+const (
+	// FlipAverage searches every piece that might turn up, and averages
+	// the results weighted by how many of each piece are still face down.
+	FlipAverage FlipPolicy = iota
+	// FlipPessimistic assumes the worst piece (for the flipper) turns up.
+	FlipPessimistic
+	// FlipOptimistic assumes the best piece (for the flipper) turns up.
+	FlipOptimistic
+)
+
+// Result describes the outcome of a search for the best move.
+type Result struct {
+	Move    move.T
+	Score   int           // from the point of view of the side to move
+	Depth   int           // the deepest iteration that completed
+	Nodes   int64         // positions visited, including quiescence
+	Elapsed time.Duration // wall-clock time spent searching
+}
+
+// AlphaBeta is a negamax search with alpha-beta pruning. It deepens
+// iteratively until its time budget runs out, and orders moves by the
+// transposition table's best move, MVV-LVA for captures, then killer
+// moves, then the history heuristic.
+type AlphaBeta struct {
+	Eval       Evaluator
+	Quiescence *Quiescence // extends leaves if set; otherwise Eval scores them
+	Flips      FlipPolicy
+	MaxDepth   int    // stop deepening here even if time remains; 0 means MaxPly
+	Table      *Table // remembers positions already searched, if set
+	Stop       *int32 // if set, the search stops once this becomes nonzero
+
+	killers    [MaxPly][2]move.T
+	history    [32][32]int
+	nodes      int64
+	deadline   time.Time
+	stopped    bool
+	startDepth int        // the first iteration's depth, if not 1
+	shuffle    *rand.Rand // perturbs the order of root moves, if set
+}
+
+// NewAlphaBeta builds a search using the given evaluator for its leaves,
+// with a quiescence search over captures.
+func NewAlphaBeta(eval Evaluator) *AlphaBeta {
+	return &AlphaBeta{Eval: eval, Quiescence: NewQuiescence(eval)}
+}
+
+// Search looks for the best move in gs, taking no more than about budget
+// to do so. A budget of zero means there is no time limit, in which case
+// MaxDepth had better be set.
+func (s *AlphaBeta) Search(gs *game.State, budget time.Duration) Result {
+	start := time.Now()
+	s.prepare(start, budget)
+	result := Result{Move: move.NewQuit(), Score: -Win}
+	maxDepth := s.MaxDepth
+	if maxDepth <= 0 || maxDepth > MaxPly {
+		maxDepth = MaxPly
+	}
+	startDepth := 1
+	if s.startDepth > 1 && s.startDepth <= maxDepth {
+		startDepth = s.startDepth
+	}
+	for depth := startDepth; depth <= maxDepth; depth++ {
+		best, score := s.root(gs, depth, result.Move)
+		if s.stopped && depth > startDepth {
+			break
+		}
+		result.Move, result.Score, result.Depth = best, score, depth
+		if s.stopped || score >= Win-MaxPly || score <= -Win+MaxPly {
+			break
+		}
+	}
+	result.Nodes = s.nodes
+	if s.Quiescence != nil {
+		result.Nodes += s.Quiescence.Nodes
+	}
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+func (s *AlphaBeta) prepare(start time.Time, budget time.Duration) {
+	s.nodes, s.stopped = 0, false
+	if s.Quiescence != nil {
+		s.Quiescence.Nodes = 0
+	}
+	s.deadline = time.Time{}
+	if budget > 0 {
+		s.deadline = start.Add(budget)
+	}
+	s.killers = [MaxPly][2]move.T{}
+	for i := range s.history {
+		for j := range s.history[i] {
+			s.history[i][j] /= 2
+		}
+	}
+}
+
+// root searches every move at the top of the tree, trying the best move
+// of the previous iteration first.
+func (s *AlphaBeta) root(gs *game.State, depth int, previous move.T) (move.T, int) {
+	moves := move.LegalMoves(gs.Us, gs.Them, gs.Board)
+	if len(moves) == 0 {
+		return move.NewQuit(), -Win
+	}
+	s.order(moves, 0, previous)
+	if s.shuffle != nil && len(moves) > 2 {
+		rest := moves[1:]
+		s.shuffle.Shuffle(len(rest), func(i, j int) {
+			rest[i], rest[j] = rest[j], rest[i]
+		})
+	}
+	best, alpha := moves[0], -Infinity
+	for _, m := range moves {
+		score := s.value(gs, m, depth-1, alpha, Infinity, 0)
+		if s.stopped {
+			break
+		}
+		if score > alpha {
+			best, alpha = m, score
+		}
+	}
+	return best, alpha
+}
+
+// negamax returns the score of gs for the side to move.
+func (s *AlphaBeta) negamax(gs *game.State, depth, alpha, beta, ply int) int {
+	s.nodes++
+	if s.nodes&1023 == 0 && s.timeIsUp() {
+		s.stopped = true
+	}
+	if s.stopped {
+		return 0
+	}
+	moves := move.LegalMoves(gs.Us, gs.Them, gs.Board)
+	if len(moves) == 0 {
+		return -Win + ply
+	}
+	if depth <= 0 || ply >= MaxPly-1 {
+		if s.Quiescence != nil {
+			return s.Quiescence.SearchAt(gs, alpha, beta, ply)
+		}
+		return s.Eval.Evaluate(gs)
+	}
+
+	key, first := uint64(0), move.NewQuit()
+	if s.Table != nil {
+		key = Hash(gs)
+		if e, ok := s.Table.Probe(key); ok {
+			first = e.Move
+			if e.Depth >= depth {
+				score := fromTable(e.Score, ply)
+				switch {
+				case e.Bound == Exact,
+					e.Bound == Lower && score >= beta,
+					e.Bound == Upper && score <= alpha:
+					return score
+				}
+			}
+		}
+	}
+
+	s.order(moves, ply, first)
+	best, bestMove, alphaOrig := -Infinity, move.NewQuit(), alpha
+	for _, m := range moves {
+		score := s.value(gs, m, depth-1, alpha, beta, ply)
+		if s.stopped {
+			return 0
+		}
+		if score > best {
+			best, bestMove = score, m
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			if m.Action() != move.Take {
+				s.rememberCutoff(m, ply, depth)
+			}
+			break
+		}
+	}
+	if s.Table != nil {
+		bound := Exact
+		if best <= alphaOrig {
+			bound = Upper
+		} else if best >= beta {
+			bound = Lower
+		}
+		s.Table.Store(Entry{Key: key, Depth: depth, Score: toTable(best, ply),
+			Bound: bound, Move: bestMove})
+	}
+	return best
+}
+
+func (s *AlphaBeta) timeIsUp() bool {
+	if s.Stop != nil && atomic.LoadInt32(s.Stop) != 0 {
+		return true
+	}
+	return !s.deadline.IsZero() && time.Now().After(s.deadline)
+}
+
+// value is the score of playing m in gs, from the point of view of the
+// player making the move.
+func (s *AlphaBeta) value(gs *game.State, m move.T, depth, alpha, beta, ply int) int {
+	if m.Action() != move.Flip {
+		child := m.Apply(gs)
+		return -s.negamax(&child, depth, -beta, -alpha, ply+1)
+	}
+
+	sum, count := 0, 0
+	worst, best := Infinity, -Infinity
+	for _, p := range downPieces {
+		n := gs.Down[p]
+		if n == 0 {
+			continue
+		}
+		child := m.ApplyFlip(gs, p)
+		var score int
+		if s.Flips == FlipAverage {
+			score = -s.negamax(&child, depth, -Infinity, Infinity, ply+1)
+		} else {
+			score = -s.negamax(&child, depth, -beta, -alpha, ply+1)
+		}
+		if s.stopped {
+			return 0
+		}
+		sum, count = sum+n*score, count+n
+		if score < worst {
+			worst = score
+		}
+		if score > best {
+			best = score
+		}
+		if s.Flips == FlipPessimistic && worst <= alpha {
+			return worst
+		}
+		if s.Flips == FlipOptimistic && best >= beta {
+			return best
+		}
+	}
+	switch s.Flips {
+	case FlipPessimistic:
+		return worst
+	case FlipOptimistic:
+		return best
+	}
+	if count == 0 {
+		return -Infinity
+	}
+	return sum / count
+}
+
+// downPieces lists every kind of piece, so that chance nodes can visit
+// the possible flips in a fixed order instead of map order.
+var downPieces = append(append([]game.Piece{}, game.RedTeam.QPHCEGK[:]...),
+	game.BlackTeam.QPHCEGK[:]...)
+
+func (s *AlphaBeta) rememberCutoff(m move.T, ply, depth int) {
+	if s.killers[ply][0] != m {
+		s.killers[ply][1] = s.killers[ply][0]
+		s.killers[ply][0] = m
+	}
+	from, to := squareIndexes(m)
+	s.history[from][to] += depth * depth
+}
+
+// order sorts moves so that the likeliest to cause a cutoff come first:
+// the given first move, captures by MVV-LVA, killers, then the rest by
+// their history scores.
+func (s *AlphaBeta) order(moves []move.T, ply int, first move.T) {
+	ranks := make(map[move.T]int, len(moves))
+	for _, m := range moves {
+		ranks[m] = s.rank(m, ply, first)
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return ranks[moves[i]] > ranks[moves[j]]
+	})
+}
+
+func (s *AlphaBeta) rank(m move.T, ply int, first move.T) int {
+	switch {
+	case m == first && m.Action() != move.Quit:
+		return 1 << 30
+	case m.Action() == move.Take:
+		return 1<<28 + mvvLva(m)
+	case m == s.killers[ply][0]:
+		return 1 << 27
+	case m == s.killers[ply][1]:
+		return 1<<27 - 1
+	}
+	from, to := squareIndexes(m)
+	return s.history[from][to]
+}
+
+// squareIndexes numbers the squares a move goes from and to, 0..31. A flip
+// goes from and to the same square.
+func squareIndexes(m move.T) (from, to int) {
+	from = m.From().Row()*8 + m.From().Col()
+	if m.Action() == move.Flip {
+		return from, from
+	}
+	return from, m.To().Row()*8 + m.To().Col()
+}
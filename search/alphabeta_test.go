@@ -0,0 +1,62 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+func TestAlphaBetaFindsWin(t *testing.T) {
+	gs := game.NewState("Red", [][]string{
+		{"g", "H", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}, []string{})
+	s := NewAlphaBeta(Material{})
+	s.MaxDepth = 4
+	result := s.Search(&gs, 0)
+	if have, want := result.Move.String(), "RedGuard at A1 takes BlackHorse at B1"; have != want {
+		t.Errorf("expected %s; got %s", want, have)
+	}
+	if result.Score != Win-1 {
+		t.Errorf("taking black's last piece should score %d; got %d", Win-1, result.Score)
+	}
+	if result.Nodes == 0 || result.Depth == 0 {
+		t.Errorf("expected search statistics; got %+v", result)
+	}
+}
+
+func TestAlphaBetaAvoidsLosingExchange(t *testing.T) {
+	// The guard can take the horse, but the king then takes the guard.
+	gs := game.NewState("Red", [][]string{
+		{"g", "H", "K", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", "p", "p"},
+	}, []string{})
+	for _, policy := range []FlipPolicy{FlipAverage, FlipPessimistic, FlipOptimistic} {
+		s := NewAlphaBeta(Material{})
+		s.Flips = policy
+		s.MaxDepth = 3
+		result := s.Search(&gs, 0)
+		if have := result.Move.String(); have == "RedGuard at A1 takes BlackHorse at B1" {
+			t.Errorf("policy %d: the guard shouldn't take the defended horse", policy)
+		}
+	}
+}
+
+func TestAlphaBetaOpening(t *testing.T) {
+	gs := game.NewState("", [][]string{
+		{"?", "?", "?", "?", "?", "?", "?", "?"},
+		{"?", "?", "?", "?", "?", "?", "?", "?"},
+		{"?", "?", "?", "?", "?", "?", "?", "?"},
+		{"?", "?", "?", "?", "?", "?", "?", "?"},
+	}, []string{})
+	s := NewAlphaBeta(Material{})
+	s.MaxDepth = 1
+	result := s.Search(&gs, 0)
+	if result.Move.String()[:4] != "flip" {
+		t.Errorf("the first move must be a flip; got %s", result.Move.String())
+	}
+}
@@ -0,0 +1,48 @@
+// Package search holds the machinery shared by the bots that look ahead
+// more than one move: position evaluators and the searches built on them.
+package search
+
+import "github.com/perlmonger42/greedy-bot/game"
+
+// Win is the score of a position that the side to move has won outright.
+// Scores within MaxPly of Win are wins (or, negated, losses) found by search.
+const Win = 1000000
+
+// Infinity is larger than any score a search can return.
+const Infinity = Win + 1
+
+// Evaluator scores a game state from the point of view of the team whose
+// turn it is: positive values favor the side to move.
+type Evaluator interface {
+	Evaluate(gs *game.State) int
+}
+
+// Bounded is implemented by evaluators that can promise the range of the
+// values they return. Searches that prune on value bounds rely on it.
+type Bounded interface {
+	Bounds() (lo, hi int)
+}
+
+// Material is the evaluator GreedyBot has always used: the game state's
+// materiel Score, seen from the side to move.
+type Material struct{}
+
+func (Material) Evaluate(gs *game.State) int {
+	if gs.Us != nil && gs.Us.K == game.BlackKing {
+		return -gs.Score
+	}
+	return gs.Score
+}
+
+// Bounds returns the extreme materiel scores: every piece of one color face
+// up, and every piece of the other color dead.
+func (Material) Bounds() (lo, hi int) {
+	counts := map[game.Piece]int{
+		game.RedCannon: 2, game.RedPawn: 5, game.RedHorse: 2, game.RedCart: 2,
+		game.RedElephant: 2, game.RedGuard: 2, game.RedKing: 1,
+	}
+	for p, n := range counts {
+		hi += 2 * n * game.PiecePoints[p]
+	}
+	return -hi, hi
+}
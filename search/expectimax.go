@@ -0,0 +1,250 @@
+package search
+
+import (
+	"sort"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// StarPruning selects how an Expectimax search cuts off chance nodes.
+type StarPruning int
+
+const (
+	// NoStar searches every outcome of a flip with a full window.
+	NoStar StarPruning = iota
+	// Star1 narrows the window of each outcome using the values already
+	// found and the evaluator's bounds on the outcomes not yet searched,
+	// and stops as soon as the flip can no longer matter.
+	Star1
+	// Star2 first probes one reply to every outcome, which cheaply bounds
+	// each outcome's value, and only then searches the outcomes as Star1.
+	Star2
+)
+
+// Expectimax is a fixed-depth negamax search that treats a flip as a
+// chance node, valued at the mean of its outcomes weighted by how many of
+// each piece are still face down. Star1 and Star2 pruning need the range
+// of possible values, so Eval should implement Bounded; otherwise the
+// bounds are ±Win, which makes the pruning much less effective.
+type Expectimax struct {
+	Eval  Evaluator
+	Star  StarPruning
+	Nodes int64
+
+	lo, hi int
+}
+
+func NewExpectimax(eval Evaluator, star StarPruning) *Expectimax {
+	e := &Expectimax{Eval: eval, Star: star, lo: -Win, hi: Win}
+	if b, ok := eval.(Bounded); ok {
+		e.lo, e.hi = b.Bounds()
+	}
+	return e
+}
+
+// Search returns the best move in gs and its expected score, looking
+// depth moves ahead.
+func (e *Expectimax) Search(gs *game.State, depth int) Result {
+	start := time.Now()
+	e.Nodes = 0
+	result := Result{Move: move.NewQuit(), Score: e.lo, Depth: depth}
+	moves := move.LegalMoves(gs.Us, gs.Them, gs.Board)
+	orderCaptures(moves)
+	alpha := -Infinity
+	for _, m := range moves {
+		if score := e.value(gs, m, depth-1, alpha, Infinity); score > alpha {
+			result.Move, alpha = m, score
+		}
+	}
+	if len(moves) > 0 {
+		result.Score = alpha
+	}
+	result.Nodes = e.Nodes
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+func (e *Expectimax) negamax(gs *game.State, depth, alpha, beta int) int {
+	e.Nodes++
+	moves := move.LegalMoves(gs.Us, gs.Them, gs.Board)
+	if len(moves) == 0 {
+		return e.lo
+	}
+	if depth <= 0 {
+		return e.evaluate(gs)
+	}
+	orderCaptures(moves)
+	best := -Infinity
+	for _, m := range moves {
+		score := e.value(gs, m, depth-1, alpha, beta)
+		if score > best {
+			best = score
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return best
+}
+
+// evaluate clamps the evaluator's score to the bounds the pruning relies on.
+func (e *Expectimax) evaluate(gs *game.State) int {
+	score := e.Eval.Evaluate(gs)
+	if score < e.lo {
+		return e.lo
+	} else if score > e.hi {
+		return e.hi
+	}
+	return score
+}
+
+// value is the score of playing m in gs, from the mover's point of view.
+func (e *Expectimax) value(gs *game.State, m move.T, depth, alpha, beta int) int {
+	if m.Action() != move.Flip {
+		child := m.Apply(gs)
+		return -e.negamax(&child, depth, -beta, -alpha)
+	}
+	outcomes := []outcome{}
+	total := 0
+	for _, p := range downPieces {
+		if n := gs.Down[p]; n > 0 {
+			outcomes = append(outcomes, outcome{state: m.ApplyFlip(gs, p), weight: n, upper: e.hi})
+			total += n
+		}
+	}
+	if total == 0 {
+		return -Infinity
+	}
+	if e.Star == NoStar {
+		sum := 0
+		for _, o := range outcomes {
+			sum += o.weight * -e.negamax(&o.state, depth, -Infinity, Infinity)
+		}
+		return floorDiv(sum, total)
+	}
+	return e.chance(outcomes, total, depth, alpha, beta)
+}
+
+// outcome is one piece a flip might turn up: the resulting state, how many
+// of that piece are face down, and an upper bound on its value to the
+// flipper.
+type outcome struct {
+	state  game.State
+	weight int
+	upper  int
+	exact  bool // upper is the outcome's actual value
+}
+
+// chance values a flip with Star1 (or Star2) pruning. All of the sums are
+// kept multiplied by total, so that the arithmetic stays in integers.
+func (e *Expectimax) chance(outcomes []outcome, total, depth, alpha, beta int) int {
+	if alpha < e.lo {
+		alpha = e.lo - 1
+	}
+	if beta > e.hi {
+		beta = e.hi + 1
+	}
+
+	if e.Star == Star2 {
+		// Probing phase: one reply to each outcome gives an upper bound on
+		// that outcome's value, since the reply's owner can do at least
+		// that well.
+		upperSum := total * e.hi
+		for i := range outcomes {
+			o := &outcomes[i]
+			rest := upperSum - o.weight*e.hi
+			a := floorDiv(alpha*total-rest, o.weight)
+			if a < e.lo {
+				a = e.lo
+			}
+			o.upper, o.exact = e.probe(&o.state, depth, a)
+			upperSum = rest + o.weight*o.upper
+			if upperSum <= alpha*total {
+				return floorDiv(upperSum, total)
+			}
+		}
+	}
+
+	// Search phase: known is the weighted sum of the outcomes searched so
+	// far; upperRest and lowerRest bound the sum of those still to come.
+	known, upperRest, lowerRest := 0, 0, 0
+	for _, o := range outcomes {
+		upperRest += o.weight * o.upper
+		lowerRest += o.weight * e.lo
+	}
+	for _, o := range outcomes {
+		upperRest -= o.weight * o.upper
+		lowerRest -= o.weight * e.lo
+		a := floorDiv(alpha*total-known-upperRest, o.weight)
+		b := ceilDiv(beta*total-known-lowerRest, o.weight)
+		if a < e.lo-1 {
+			a = e.lo - 1
+		}
+		if b > o.upper+1 {
+			b = o.upper + 1
+		}
+		if o.exact {
+			known += o.weight * o.upper
+		} else {
+			known += o.weight * -e.negamax(&o.state, depth, -b, -a)
+		}
+		if known+upperRest <= alpha*total {
+			return floorDiv(known+upperRest, total)
+		}
+		if known+lowerRest >= beta*total {
+			return floorDiv(known+lowerRest, total)
+		}
+	}
+	return floorDiv(known, total)
+}
+
+// probe searches just the first reply in gs, and returns an upper bound on
+// the value of gs to the player who is not on move. If that bound would be
+// no better than alpha, the reply is searched only well enough to prove it.
+// At a leaf the probe is as good as a search, so it reports an exact value.
+func (e *Expectimax) probe(gs *game.State, depth, alpha int) (upper int, exact bool) {
+	e.Nodes++
+	moves := move.LegalMoves(gs.Us, gs.Them, gs.Board)
+	if len(moves) == 0 {
+		return e.hi, true
+	}
+	if depth <= 0 {
+		return -e.evaluate(gs), true
+	}
+	orderCaptures(moves)
+	// The reply's owner searches with window -hi-1..-alpha; a result below
+	// the window says nothing about the position, only about this reply.
+	reply := e.value(gs, moves[0], depth-1, -e.hi-1, -alpha)
+	if reply <= -e.hi-1 || -reply > e.hi {
+		return e.hi, false
+	}
+	return -reply, false
+}
+
+// orderCaptures moves captures to the front, most valuable victim first.
+func orderCaptures(moves []move.T) {
+	sort.SliceStable(moves, func(i, j int) bool {
+		ti, tj := moves[i].Action() == move.Take, moves[j].Action() == move.Take
+		if ti != tj {
+			return ti
+		}
+		return ti && mvvLva(moves[i]) > mvvLva(moves[j])
+	})
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+func ceilDiv(a, b int) int {
+	return -floorDiv(-a, b)
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// starSuite is a fixed set of positions, each with a few pieces still face
+// down, for comparing the chance-node pruning strategies.
+var starSuite = []game.State{
+	game.NewState("Red", [][]string{
+		{"?", ".", "?", ".", "?", "E", ".", "?"},
+		{".", "?", "p", ".", "H", "k", "?", "e"},
+		{"g", "G", "C", "Q", ".", "p", ".", "q"},
+		{"h", "p", ".", "?", "c", ".", "P", "?"},
+	}, []string{"P", "P", "h", "c"}),
+	game.NewState("Black", [][]string{
+		{".", ".", "?", ".", ".", "E", ".", "."},
+		{".", "?", "p", ".", "H", "k", ".", "e"},
+		{"g", ".", "C", "Q", ".", "?", ".", "q"},
+		{".", "p", ".", "?", "c", ".", "P", "?"},
+	}, []string{"P", "P", "h", "c", "G", "p"}),
+	game.NewState("Red", [][]string{
+		{"?", "?", "?", "?", "?", "?", "?", "?"},
+		{"?", "?", "?", "k", "?", "?", "?", "?"},
+		{"?", "?", "?", "?", "C", "?", "?", "?"},
+		{"?", "?", "?", "?", "?", "?", "?", "?"},
+	}, []string{}),
+}
+
+func TestStarPruningAgreesWithExpectimax(t *testing.T) {
+	for i := range starSuite {
+		gs := &starSuite[i]
+		plain := NewExpectimax(Material{}, NoStar).Search(gs, 2)
+		for _, star := range []StarPruning{Star1, Star2} {
+			pruned := NewExpectimax(Material{}, star).Search(gs, 2)
+			if pruned.Score != plain.Score {
+				t.Errorf("position %d, star%d: expected score %d; got %d",
+					i, star, plain.Score, pruned.Score)
+			}
+			if pruned.Nodes > plain.Nodes {
+				t.Errorf("position %d, star%d: searched %d nodes, more than plain expectimax's %d",
+					i, star, pruned.Nodes, plain.Nodes)
+			}
+		}
+	}
+}
+
+func benchmarkExpectimax(b *testing.B, star StarPruning) {
+	nodes := int64(0)
+	for n := 0; n < b.N; n++ {
+		for i := range starSuite {
+			nodes += NewExpectimax(Material{}, star).Search(&starSuite[i], 2).Nodes
+		}
+	}
+	b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+}
+
+func BenchmarkExpectimax(b *testing.B) { benchmarkExpectimax(b, NoStar) }
+func BenchmarkStar1(b *testing.B)      { benchmarkExpectimax(b, Star1) }
+func BenchmarkStar2(b *testing.B)      { benchmarkExpectimax(b, Star2) }
@@ -0,0 +1,21 @@
+package search
+
+import (
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// pieceValue is the unsigned point value of a piece.
+func pieceValue(p game.Piece) int {
+	if v := game.PiecePoints[p]; v < 0 {
+		return -v
+	} else {
+		return v
+	}
+}
+
+// mvvLva ranks a capture: most valuable victim first, and among equal
+// victims, least valuable attacker first.
+func mvvLva(m move.T) int {
+	return pieceValue(m.Killed())*1024 - pieceValue(m.Actor())
+}
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// Parallel runs several AlphaBeta searches of the same position at once
+// ("lazy SMP"). The workers share a transposition table, so each benefits
+// from what the others have found; the helpers start at staggered depths
+// and try the root moves in shuffled orders, so that they tend to explore
+// different parts of the tree. The first worker's result is the answer.
+//
+// With one worker, no randomness is involved, and with no time budget the
+// search is entirely reproducible.
+type Parallel struct {
+	Eval     Evaluator
+	Flips    FlipPolicy
+	MaxDepth int
+	Workers  int    // number of goroutines to search with; at least 1
+	Seed     int64  // seeds the helpers' shuffling of root moves
+	Table    *Table // shared by all of the workers
+
+	workers []*AlphaBeta
+}
+
+// DefaultTableSize is the number of transposition table entries a Parallel
+// search gets unless told otherwise.
+const DefaultTableSize = 1 << 18
+
+func NewParallel(eval Evaluator, workers int) *Parallel {
+	return &Parallel{Eval: eval, Workers: workers, Table: NewTable(DefaultTableSize)}
+}
+
+// Search looks for the best move in gs using all of the workers, taking no
+// more than about budget to do so. The Nodes in the result are summed over
+// all of the workers; the Depth is the first worker's.
+func (p *Parallel) Search(gs *game.State, budget time.Duration) Result {
+	return p.SearchContext(context.Background(), gs, budget)
+}
+
+// SearchContext is Search, but it also stops when ctx is done. The result
+// of a cancelled search is whatever the first worker had found so far.
+func (p *Parallel) SearchContext(ctx context.Context, gs *game.State, budget time.Duration) Result {
+	var stop int32
+	p.prepare(&stop)
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			atomic.StoreInt32(&stop, 1)
+		case <-finished:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	helperNodes := make([]int64, len(p.workers))
+	for i := 1; i < len(p.workers); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			state := gs.Clone()
+			helperNodes[i] = p.workers[i].Search(&state, budget).Nodes
+		}(i)
+	}
+	result := p.workers[0].Search(gs, budget)
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+	for _, n := range helperNodes {
+		result.Nodes += n
+	}
+	return result
+}
+
+// prepare makes sure there are the right number of workers, configured
+// the way p is.
+func (p *Parallel) prepare(stop *int32) {
+	n := p.Workers
+	if n < 1 {
+		n = 1
+	}
+	if p.Table == nil {
+		p.Table = NewTable(DefaultTableSize)
+	}
+	for len(p.workers) < n {
+		p.workers = append(p.workers, &AlphaBeta{})
+	}
+	p.workers = p.workers[:n]
+	for i, w := range p.workers {
+		w.Eval, w.Flips, w.MaxDepth = p.Eval, p.Flips, p.MaxDepth
+		w.Table, w.Stop = p.Table, stop
+		if w.Quiescence == nil {
+			w.Quiescence = NewQuiescence(p.Eval)
+		}
+		w.Quiescence.Eval = p.Eval
+		if i > 0 {
+			w.startDepth = 1 + i%2
+			w.shuffle = rand.New(rand.NewSource(p.Seed + int64(i)))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+func TestParallelSingleWorkerIsDeterministic(t *testing.T) {
+	gs := starSuite[0]
+	var results []Result
+	for i := 0; i < 2; i++ {
+		p := NewParallel(Material{}, 1)
+		p.Seed, p.MaxDepth = 42, 2
+		results = append(results, p.Search(&gs, 0))
+	}
+	a, b := results[0], results[1]
+	if a.Move != b.Move || a.Score != b.Score || a.Nodes != b.Nodes {
+		t.Errorf("two identical searches differed: %s/%d/%d vs %s/%d/%d",
+			a.Move.String(), a.Score, a.Nodes, b.Move.String(), b.Score, b.Nodes)
+	}
+}
+
+func TestParallelWorkersFindWin(t *testing.T) {
+	gs := game.NewState("Black", [][]string{
+		{"p", ".", "G", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{"E", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}, []string{})
+	p := NewParallel(Material{}, 4)
+	p.MaxDepth = 4
+	result := p.Search(&gs, 0)
+	if result.Score < Win-MaxPly {
+		t.Errorf("black should see a forced win; got %d with %s",
+			result.Score, result.Move.String())
+	}
+}
+
+func TestTableMateScoresAreRelative(t *testing.T) {
+	table := NewTable(16)
+	table.Store(Entry{Key: 99, Depth: 3, Score: toTable(Win-7, 4), Bound: Exact})
+	e, ok := table.Probe(99)
+	if !ok {
+		t.Fatalf("expected to find the entry just stored")
+	}
+	if have := fromTable(e.Score, 2); have != Win-5 {
+		t.Errorf("a win 3 plies below ply 4 is 3 plies below ply 2 (%d); got %d", Win-5, have)
+	}
+	if _, ok := table.Probe(99 + 16); ok {
+		t.Errorf("a different key in the same slot shouldn't match")
+	}
+}
+
+// sliceEval is an evaluator whose dynamic type can't be compared with ==.
+type sliceEval []int
+
+func (sliceEval) Evaluate(gs *game.State) int {
+	return Material{}.Evaluate(gs)
+}
+
+func TestParallelNonComparableEvaluator(t *testing.T) {
+	gs := starSuite[0]
+	p := NewParallel(sliceEval{1}, 2)
+	p.MaxDepth = 2
+	p.Search(&gs, 0)
+	p.Eval = sliceEval{2}
+	p.Search(&gs, 0)
+	for i, w := range p.workers {
+		if e, ok := w.Quiescence.Eval.(sliceEval); !ok || e[0] != 2 {
+			t.Errorf("worker %d's quiescence search still uses %v", i, w.Quiescence.Eval)
+		}
+	}
+}
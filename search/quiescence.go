@@ -0,0 +1,135 @@
+package search
+
+import (
+	"sort"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// Quiescence extends the leaves of a depth-limited search until the
+// position is quiet, so that an exchange of pieces isn't judged halfway
+// through. Only captures are searched, and the side to move may always
+// "stand pat" on the evaluator's score instead of capturing.
+type Quiescence struct {
+	Eval Evaluator
+
+	// HangingFlips also searches flips next to any of our pieces that the
+	// enemy could take, since turning up a defender is often the only way
+	// to save a hanging piece. Flips are only tried at the first ply.
+	HangingFlips bool
+
+	// MaxDepth limits how many captures deep the search will go;
+	// zero means no limit.
+	MaxDepth int
+
+	Nodes int64 // positions visited, summed over all searches
+}
+
+// NewQuiescence builds a capture-only search using the given evaluator.
+func NewQuiescence(eval Evaluator) *Quiescence {
+	return &Quiescence{Eval: eval}
+}
+
+// Search returns the score of gs for the side to move, within the window
+// alpha..beta.
+func (q *Quiescence) Search(gs *game.State, alpha, beta int) int {
+	return q.SearchAt(gs, alpha, beta, 0)
+}
+
+// SearchAt is Search for a position ply moves below the root of some larger
+// search, so that a lost position is scored by how soon it happens.
+func (q *Quiescence) SearchAt(gs *game.State, alpha, beta, ply int) int {
+	return q.search(gs, alpha, beta, ply, 0)
+}
+
+func (q *Quiescence) search(gs *game.State, alpha, beta, ply, depth int) int {
+	q.Nodes++
+	if gs.Us == nil { // nobody has flipped yet, so there's nothing to take
+		return q.Eval.Evaluate(gs)
+	}
+	moves := move.LegalMoves(gs.Us, gs.Them, gs.Board)
+	if len(moves) == 0 {
+		return -Win + ply
+	}
+
+	standPat := q.Eval.Evaluate(gs)
+	if standPat >= beta {
+		return standPat
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+	if q.MaxDepth > 0 && depth >= q.MaxDepth {
+		return alpha
+	}
+
+	takes := []move.T{}
+	for _, m := range moves {
+		if m.Action() == move.Take {
+			takes = append(takes, m)
+		}
+	}
+	sort.SliceStable(takes, func(i, j int) bool {
+		return mvvLva(takes[i]) > mvvLva(takes[j])
+	})
+	for _, m := range takes {
+		child := m.Apply(gs)
+		score := -q.search(&child, -beta, -alpha, ply+1, depth+1)
+		if score >= beta {
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	if q.HangingFlips && depth == 0 {
+		for _, m := range hangingFlips(gs, moves) {
+			score := q.flipValue(gs, m, ply, depth)
+			if score >= beta {
+				return score
+			}
+			if score > alpha {
+				alpha = score
+			}
+		}
+	}
+	return alpha
+}
+
+// flipValue is the expected score of flipping, averaged over every piece
+// that might turn up.
+func (q *Quiescence) flipValue(gs *game.State, m move.T, ply, depth int) int {
+	sum, count := 0, 0
+	for p, n := range gs.Down {
+		child := m.ApplyFlip(gs, p)
+		sum += n * -q.search(&child, -Infinity, Infinity, ply+1, depth+1)
+		count += n
+	}
+	if count == 0 {
+		return -Infinity
+	}
+	return sum / count
+}
+
+// hangingFlips returns those flips among moves that are next to one of our
+// pieces that the enemy could take.
+func hangingFlips(gs *game.State, moves []move.T) []move.T {
+	hanging := [4][8]bool{}
+	for _, m := range move.Captures(gs.Them, gs.Us, gs.Board) {
+		hanging[m.To().Row()][m.To().Col()] = true
+	}
+	flips := []move.T{}
+	for _, m := range moves {
+		if m.Action() != move.Flip {
+			continue
+		}
+		r, c := m.From().Row(), m.From().Col()
+		if (r > 0 && hanging[r-1][c]) || (r < 3 && hanging[r+1][c]) ||
+			(c > 0 && hanging[r][c-1]) || (c < 7 && hanging[r][c+1]) {
+			flips = append(flips, m)
+		}
+	}
+	return flips
+}
@@ -0,0 +1,51 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+func TestQuiescenceTakesFreePiece(t *testing.T) {
+	gs := game.NewState("Red", [][]string{
+		{"g", "H", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "P"},
+	}, []string{})
+	q := NewQuiescence(Material{})
+	have := q.Search(&gs, -Infinity, Infinity)
+	want := Material{}.Evaluate(&gs) + 2*game.PiecePoints[game.RedHorse]
+	if have != want {
+		t.Errorf("taking the undefended horse should score %d; got %d", want, have)
+	}
+}
+
+func TestQuiescenceAvoidsLosingExchange(t *testing.T) {
+	// The guard can take the horse, but the king then takes the guard.
+	gs := game.NewState("Red", [][]string{
+		{"g", "H", "K", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "p"},
+	}, []string{})
+	q := NewQuiescence(Material{})
+	have := q.Search(&gs, -Infinity, Infinity)
+	want := Material{}.Evaluate(&gs)
+	if have != want {
+		t.Errorf("red should stand pat at %d; got %d", want, have)
+	}
+}
+
+func TestQuiescenceLostPosition(t *testing.T) {
+	gs := game.NewState("Black", [][]string{
+		{"g", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}, []string{})
+	q := NewQuiescence(Material{})
+	if have := q.Search(&gs, -Infinity, Infinity); have != -Win {
+		t.Errorf("black has no pieces and should have lost; got %d", have)
+	}
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"sync"
+
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// Bound says how a score stored in the transposition table relates to the
+// position's true score.
+type Bound uint8
+
+const (
+	Exact Bound = iota
+	Lower       // the true score is at least this
+	Upper       // the true score is at most this
+)
+
+// Entry is what the transposition table remembers about a position.
+type Entry struct {
+	Key   uint64
+	Depth int
+	Score int
+	Bound Bound
+	Move  move.T // best move found, or a Quit if there wasn't one
+}
+
+// Table is a transposition table that may be shared by several searches
+// running at once. Access is guarded by a set of striped locks, so that
+// workers rarely wait on one another.
+type Table struct {
+	entries []Entry
+	mask    uint64
+	locks   [256]sync.Mutex
+}
+
+// NewTable builds a table with room for at least size entries.
+func NewTable(size int) *Table {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &Table{entries: make([]Entry, n), mask: uint64(n - 1)}
+}
+
+// Probe returns the table's entry for key, if it has one.
+func (t *Table) Probe(key uint64) (Entry, bool) {
+	i := key & t.mask
+	lock := &t.locks[i&255]
+	lock.Lock()
+	e := t.entries[i]
+	lock.Unlock()
+	return e, e.Key == key && key != 0
+}
+
+// Store remembers a search result. It replaces whatever was in the slot,
+// unless the slot holds a deeper search of the same position.
+func (t *Table) Store(e Entry) {
+	i := e.Key & t.mask
+	lock := &t.locks[i&255]
+	lock.Lock()
+	if old := t.entries[i]; old.Key != e.Key || old.Depth <= e.Depth {
+		t.entries[i] = e
+	}
+	lock.Unlock()
+}
+
+// Clear forgets everything in the table.
+func (t *Table) Clear() {
+	for i := range t.locks {
+		t.locks[i].Lock()
+	}
+	for i := range t.entries {
+		t.entries[i] = Entry{}
+	}
+	for i := range t.locks {
+		t.locks[i].Unlock()
+	}
+}
+
+// Scores of won and lost positions depend on how far they are from the
+// root, so the table stores them relative to the position itself.
+func toTable(score, ply int) int {
+	if score >= Win-MaxPly {
+		return score + ply
+	} else if score <= -Win+MaxPly {
+		return score - ply
+	}
+	return score
+}
+
+func fromTable(score, ply int) int {
+	if score >= Win-MaxPly {
+		return score - ply
+	} else if score <= -Win+MaxPly {
+		return score + ply
+	}
+	return score
+}
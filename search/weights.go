@@ -0,0 +1,193 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// Kinds names the kinds of piece, in QPHCEGK order, as used in weights files.
+var Kinds = [7]string{"cannon", "pawn", "horse", "cart", "elephant", "guard", "king"}
+
+// Weights are the parameters of the Linear evaluator.
+type Weights struct {
+	Pieces   map[string]int `json:"pieces"`   // the value of each kind of piece
+	Corner   int            `json:"corner"`   // for each face-up piece in a corner
+	Edge     int            `json:"edge"`     // for each face-up piece on an edge
+	Mobility int            `json:"mobility"` // for each legal move
+}
+
+// DefaultWeights reproduces the materiel score from game.PiecePoints.
+func DefaultWeights() Weights {
+	w := Weights{Pieces: map[string]int{}}
+	for i, kind := range Kinds {
+		w.Pieces[kind] = game.PiecePoints[game.RedTeam.QPHCEGK[i]]
+	}
+	return w
+}
+
+// LoadWeights reads a weights file written by Save. Kinds missing from the
+// file keep their default values.
+func LoadWeights(path string) (Weights, error) {
+	w := DefaultWeights()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return w, err
+	}
+	if err := json.Unmarshal(data, &w); err != nil {
+		return w, fmt.Errorf("%s: %v", path, err)
+	}
+	for kind := range w.Pieces {
+		if kindIndex(kind) < 0 {
+			return w, fmt.Errorf("%s: unknown kind of piece %q", path, kind)
+		}
+	}
+	return w, nil
+}
+
+// Save writes the weights to path as JSON.
+func (w Weights) Save(path string) error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// InstallPiecePoints makes the weights' piece values the ones used by
+// game.PiecePoints, and so by game states' Score and by GreedyBot. It must
+// be called before any games start.
+func (w Weights) InstallPiecePoints() {
+	for p, v := range w.PieceValues() {
+		game.PiecePoints[p] = v
+	}
+}
+
+// PieceValues is a table of piece values like game.PiecePoints, red
+// positive and black negative, holding the weights' values. Kinds missing
+// from the weights keep their values from game.PiecePoints.
+func (w Weights) PieceValues() map[game.Piece]int {
+	values := map[game.Piece]int{}
+	for p, v := range game.PiecePoints {
+		values[p] = v
+	}
+	for i, kind := range Kinds {
+		if v, ok := w.Pieces[kind]; ok {
+			values[game.RedTeam.QPHCEGK[i]] = v
+			values[game.BlackTeam.QPHCEGK[i]] = -v
+		}
+	}
+	return values
+}
+
+func kindIndex(kind string) int {
+	for i, k := range Kinds {
+		if k == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// NumFeatures is the length of the vectors returned by Features and Vector.
+const NumFeatures = len(Kinds) + 3
+
+// Vector lists the weights in the same order as Features.
+func (w Weights) Vector() []float64 {
+	v := make([]float64, NumFeatures)
+	for i, kind := range Kinds {
+		v[i] = float64(w.Pieces[kind])
+	}
+	v[len(Kinds)], v[len(Kinds)+1], v[len(Kinds)+2] =
+		float64(w.Corner), float64(w.Edge), float64(w.Mobility)
+	return v
+}
+
+// WeightsFromVector is the inverse of Vector, rounding to integers.
+func WeightsFromVector(v []float64) Weights {
+	round := func(f float64) int {
+		if f < 0 {
+			return int(f - 0.5)
+		}
+		return int(f + 0.5)
+	}
+	w := Weights{Pieces: map[string]int{}}
+	for i, kind := range Kinds {
+		w.Pieces[kind] = round(v[i])
+	}
+	w.Corner, w.Edge, w.Mobility =
+		round(v[len(Kinds)]), round(v[len(Kinds)+1]), round(v[len(Kinds)+2])
+	return w
+}
+
+// Features measures gs from the side to move's point of view, so that its
+// evaluation is the dot product of the features and the weights' Vector.
+// For each kind of piece, the feature counts the pieces face up on the
+// board (ours less theirs) and the dead (theirs less ours), matching the
+// way game.State's Score counts materiel.
+func Features(gs *game.State) []float64 {
+	f := make([]float64, NumFeatures)
+	if gs.Us == nil {
+		return f
+	}
+	side := func(p game.Piece) float64 {
+		if gs.Us.Contains(p) {
+			return 1
+		} else if gs.Them.Contains(p) {
+			return -1
+		}
+		return 0
+	}
+	for r, row := range gs.Board {
+		for c, p := range row {
+			s := side(p)
+			if s == 0 {
+				continue
+			}
+			f[pieceKind(p)] += s
+			onEdgeRow, onEdgeCol := r == 0 || r == 3, c == 0 || c == 7
+			if onEdgeRow && onEdgeCol {
+				f[len(Kinds)] += s
+			} else if onEdgeRow || onEdgeCol {
+				f[len(Kinds)+1] += s
+			}
+		}
+	}
+	for _, p := range gs.Dead {
+		f[pieceKind(p)] -= side(p)
+	}
+	ours := len(move.LegalMoves(gs.Us, gs.Them, gs.Board))
+	theirs := len(move.LegalMoves(gs.Them, gs.Us, gs.Board))
+	f[len(Kinds)+2] = float64(ours - theirs)
+	return f
+}
+
+// pieceKind returns the index in Kinds of a face-up piece.
+func pieceKind(p game.Piece) int {
+	for i := range Kinds {
+		if game.RedTeam.QPHCEGK[i] == p || game.BlackTeam.QPHCEGK[i] == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// Linear evaluates positions as a weighted sum of their Features.
+type Linear struct {
+	weights []float64
+}
+
+func NewLinear(w Weights) *Linear {
+	return &Linear{weights: w.Vector()}
+}
+
+func (l *Linear) Evaluate(gs *game.State) int {
+	score := 0.0
+	for i, f := range Features(gs) {
+		score += f * l.weights[i]
+	}
+	return int(score)
+}
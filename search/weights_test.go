@@ -0,0 +1,46 @@
+package search
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultWeightsMatchMaterial(t *testing.T) {
+	linear := NewLinear(DefaultWeights())
+	for i := range starSuite {
+		gs := &starSuite[i]
+		if have, want := linear.Evaluate(gs), (Material{}).Evaluate(gs); have != want {
+			t.Errorf("position %d: expected %d; got %d", i, want, have)
+		}
+	}
+}
+
+func TestWeightsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := DefaultWeights()
+	w.Pieces["king"], w.Mobility = 650, 3
+	path := filepath.Join(dir, "weights.json")
+	if err := w.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadWeights(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Pieces["king"] != 650 || loaded.Mobility != 3 || loaded.Pieces["pawn"] != 1 {
+		t.Errorf("weights changed in the file: %+v", loaded)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	ioutil.WriteFile(bad, []byte(`{"pieces": {"queen": 9}}`), 0644)
+	if _, err := LoadWeights(bad); err == nil {
+		t.Errorf("expected an error for an unknown kind of piece")
+	}
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"math/bits"
+	"math/rand"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// Zobrist keys: one per (square, piece) pair, one per (piece, count) pair
+// for the pieces still face down, and a couple for the side to move. The
+// keys come from a fixed seed, so hashes are the same from run to run.
+var (
+	squareKeys [32][16]uint64
+	downKeys   [16][6]uint64
+	blackKey   uint64
+	nobodyKey  uint64
+)
+
+func init() {
+	r := rand.New(rand.NewSource(1960))
+	for sq := range squareKeys {
+		for p := range squareKeys[sq] {
+			squareKeys[sq][p] = r.Uint64()
+		}
+	}
+	for p := range downKeys {
+		for n := range downKeys[p] {
+			downKeys[p][n] = r.Uint64()
+		}
+	}
+	blackKey, nobodyKey = r.Uint64(), r.Uint64()
+}
+
+// Hash returns a Zobrist hash of a game state: its board, the pieces still
+// face down, and the side to move. The boneyard isn't hashed, since it is
+// determined by the board and the face-down pieces.
+func Hash(gs *game.State) uint64 {
+	h := uint64(0)
+	for r, row := range gs.Board {
+		for c, p := range row {
+			if p != game.None {
+				h ^= squareKeys[r*8+c][pieceIndex(p)]
+			}
+		}
+	}
+	for p, n := range gs.Down {
+		if n > 5 {
+			n = 5
+		}
+		h ^= downKeys[pieceIndex(p)][n]
+	}
+	if gs.Us == nil {
+		h ^= nobodyKey
+	} else if gs.Us.K == game.BlackKing {
+		h ^= blackKey
+	}
+	return h
+}
+
+// pieceIndex numbers the pieces 1..15, by the position of their bit.
+func pieceIndex(p game.Piece) int {
+	return bits.TrailingZeros16(uint16(p)) & 15
+}
@@ -0,0 +1,185 @@
+// Package selfplay plays bots against each other from random deals, and
+// records the games, so that the bots can learn from them.
+package selfplay
+
+import (
+	"math/rand"
+
+	"github.com/perlmonger42/greedy-bot/dataset"
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// Bot is anything that can choose moves; pao.Bot is one.
+type Bot interface {
+	Name() string
+	ChooseMove(*game.State) move.T
+}
+
+// Scorer is implemented by bots that can report the search score of the
+// move they chose last, from their own point of view.
+type Scorer interface {
+	LastScore() int
+}
+
+// Deal is the hidden layout of the pieces at the start of a game.
+type Deal [4][8]game.Piece
+
+// NewDeal shuffles all 32 pieces onto the board.
+func NewDeal(r *rand.Rand) Deal {
+	pieces := []game.Piece{}
+	for _, team := range game.Teams {
+		for _, p := range team.QPHCEGK {
+			for n := startingCount[p]; n > 0; n-- {
+				pieces = append(pieces, p)
+			}
+		}
+	}
+	r.Shuffle(len(pieces), func(i, j int) { pieces[i], pieces[j] = pieces[j], pieces[i] })
+	deal := Deal{}
+	for i, p := range pieces {
+		deal[i/8][i%8] = p
+	}
+	return deal
+}
+
+var startingCount = map[game.Piece]int{
+	game.RedCannon: 2, game.RedPawn: 5, game.RedHorse: 2, game.RedCart: 2,
+	game.RedElephant: 2, game.RedGuard: 2, game.RedKing: 1,
+	game.BlackCannon: 2, game.BlackPawn: 5, game.BlackHorse: 2, game.BlackCart: 2,
+	game.BlackElephant: 2, game.BlackGuard: 2, game.BlackKing: 1,
+}
+
+// Ply is one move of a recorded game.
+type Ply struct {
+	Before   game.State // the position the mover saw
+	Mover    string     // "Red" or "Black"
+	Move     move.T
+	Revealed game.Piece // the piece turned up, if Move is a flip
+	Score    *int       // the mover's score for Move, if it is a Scorer
+}
+
+// Record is a complete game.
+type Record struct {
+	Players [2]string // names of the bots, the first mover first
+	First   string    // the color of the first mover
+	Plies   []Ply
+	Winner  string // "Red", "Black", or "" for a draw
+}
+
+// Options controls how games are played out.
+type Options struct {
+	// MaxQuietPlies ends the game in a draw after this many plies in a row
+	// without a flip or capture; zero means DefaultMaxQuietPlies.
+	MaxQuietPlies int
+}
+
+const DefaultMaxQuietPlies = 100
+
+// Play plays one game between first and second, who moves first, on the
+// given deal. A bot that resigns, or tries an illegal move, loses; so does
+// a bot with no legal moves.
+func Play(first, second Bot, deal Deal, opt Options) Record {
+	maxQuiet := opt.MaxQuietPlies
+	if maxQuiet <= 0 {
+		maxQuiet = DefaultMaxQuietPlies
+	}
+	rows := make([][]string, 4)
+	for r := range rows {
+		rows[r] = []string{"?", "?", "?", "?", "?", "?", "?", "?"}
+	}
+	gs := game.NewState("", rows, []string{})
+	record := Record{Players: [2]string{first.Name(), second.Name()}}
+	bots := [2]Bot{first, second}
+
+	for turn, quiet := 0, 0; quiet < maxQuiet; turn++ {
+		mover := bots[turn%2]
+		var color string
+		if gs.Us != nil {
+			color = gs.Us.Color()
+			if len(move.LegalMoves(gs.Us, gs.Them, gs.Board)) == 0 {
+				record.Winner = gs.Them.Color()
+				return record
+			}
+		}
+		view := gs.Clone()
+		m := mover.ChooseMove(&view)
+		if !isLegal(&gs, m) {
+			if gs.Us == nil { // resigning before the colors are even known
+				record.Winner = ""
+			} else {
+				record.Winner = gs.Them.Color()
+			}
+			return record
+		}
+
+		ply := Ply{Before: gs.Clone(), Move: m}
+		if scorer, ok := mover.(Scorer); ok {
+			score := scorer.LastScore()
+			ply.Score = &score
+		}
+		if m.Action() == move.Flip {
+			ply.Revealed = deal[m.From().Row()][m.From().Col()]
+			gs = m.ApplyFlip(&gs, ply.Revealed)
+			quiet = 0
+		} else {
+			if m.Action() == move.Take {
+				quiet = 0
+			} else {
+				quiet++
+			}
+			gs = m.Apply(&gs)
+		}
+		if color == "" { // the first flip decides the colors
+			color = gs.Them.Color()
+			record.First = color
+		}
+		ply.Mover = color
+		record.Plies = append(record.Plies, ply)
+	}
+	return record
+}
+
+func isLegal(gs *game.State, m move.T) bool {
+	if m.Action() == move.Quit {
+		return false
+	}
+	for _, legal := range move.LegalMoves(gs.Us, gs.Them, gs.Board) {
+		if legal == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Result returns the score of a game for color: 1 for a win, 0 for a
+// loss, and one half for a draw.
+func (r *Record) Result(color string) float64 {
+	switch r.Winner {
+	case "":
+		return 0.5
+	case color:
+		return 1
+	}
+	return 0
+}
+
+// Positions lists the positions of the game in which the side to move was
+// known (that is, all but the first), for a dataset in which this is the
+// game'th game.
+func (r *Record) Positions(game int) []dataset.Position {
+	positions := []dataset.Position{}
+	for _, ply := range r.Plies {
+		if ply.Before.Us == nil {
+			continue
+		}
+		p := dataset.NewPosition(&ply.Before, r.Result(ply.Mover))
+		p.Game, p.Ply = game, len(positions)
+		p.Move, p.Score = ply.Move.Command().Argument, ply.Score
+		positions = append(positions, p)
+	}
+	for i := range positions {
+		positions[i].Plies = len(positions)
+	}
+	return positions
+}
@@ -0,0 +1,78 @@
+package selfplay
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// firstMover always plays the first legal move.
+type firstMover struct{}
+
+func (firstMover) Name() string { return "First" }
+
+func (firstMover) ChooseMove(gs *game.State) move.T {
+	if moves := move.LegalMoves(gs.Us, gs.Them, gs.Board); len(moves) > 0 {
+		return moves[0]
+	}
+	return move.NewQuit()
+}
+
+func TestNewDeal(t *testing.T) {
+	deal := NewDeal(rand.New(rand.NewSource(7)))
+	counts := map[game.Piece]int{}
+	for _, row := range deal {
+		for _, p := range row {
+			counts[p]++
+		}
+	}
+	for p, n := range startingCount {
+		if counts[p] != n {
+			t.Errorf("expected %d of %s; got %d", n, p, counts[p])
+		}
+	}
+}
+
+func TestPlay(t *testing.T) {
+	deal := NewDeal(rand.New(rand.NewSource(7)))
+	rec := Play(firstMover{}, firstMover{}, deal, Options{MaxQuietPlies: 20})
+	if len(rec.Plies) < 32 {
+		t.Fatalf("every piece should be flipped before the game ends; got %d plies", len(rec.Plies))
+	}
+	first := rec.Plies[0]
+	if first.Revealed != deal[0][0] || first.Mover != game.TeamOf(deal[0][0]).Color() {
+		t.Errorf("the first flip should reveal %s for its color; got %s for %s",
+			deal[0][0], first.Revealed, first.Mover)
+	}
+	if rec.First != first.Mover {
+		t.Errorf("expected the first mover to be %s; got %s", first.Mover, rec.First)
+	}
+	for i, ply := range rec.Plies[1:] {
+		if ply.Mover == rec.Plies[i].Mover {
+			t.Fatalf("ply %d: %s moved twice in a row", i+1, ply.Mover)
+		}
+	}
+	again := Play(firstMover{}, firstMover{}, deal, Options{MaxQuietPlies: 20})
+	if again.Winner != rec.Winner || len(again.Plies) != len(rec.Plies) {
+		t.Errorf("the same bots on the same deal should play the same game")
+	}
+}
+
+func TestPositions(t *testing.T) {
+	deal := NewDeal(rand.New(rand.NewSource(7)))
+	rec := Play(firstMover{}, firstMover{}, deal, Options{MaxQuietPlies: 20})
+	positions := rec.Positions(3)
+	if len(positions) != len(rec.Plies)-1 {
+		t.Fatalf("expected every position but the first; got %d of %d", len(positions), len(rec.Plies))
+	}
+	for i, p := range positions {
+		if p.Game != 3 || p.Ply != i || p.Plies != len(positions) {
+			t.Fatalf("position %d: got game %d, ply %d of %d", i, p.Game, p.Ply, p.Plies)
+		}
+		if p.Move == "" {
+			t.Errorf("position %d: the move played is missing", i)
+		}
+	}
+}
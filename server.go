@@ -2,20 +2,114 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/perlmonger42/greedy-bot/admin"
+	"github.com/perlmonger42/greedy-bot/config"
+	"github.com/perlmonger42/greedy-bot/logging"
+	"github.com/perlmonger42/greedy-bot/metrics"
+	"github.com/perlmonger42/greedy-bot/opponent"
 	"github.com/perlmonger42/greedy-bot/pao"
+	"github.com/perlmonger42/greedy-bot/search"
 )
 
 type WebsocketService interface {
 	Run(*websocket.Conn)
+	Status() pao.Status
+	Terminate()
+	Resign()
 }
 
-var PaoService WebsocketService = pao.NewService()
+// log is the server's own logger. Each session's loggers add its ID.
+var log = logging.Default.Sub("server")
+
+// sessions counts the connections so far, to give each session an ID.
+var sessions int64
+
+// active lists the sessions in progress, for the admin API.
+var active = admin.NewRegistry()
+
+// ready is set once the server is ready to take connections, and cleared
+// again when it starts shutting down.
+var ready int32
+
+// grace is how long a shutting-down server waits for games in progress to
+// finish before resigning them. GREEDY_GRACE can change it.
+var grace = 5 * time.Minute
+
+// malformed says what sessions do with messages they can't make sense of.
+// GREEDY_MALFORMED can change it to "ignore" or "abort".
+var malformed = pao.LogMalformed
+
+// opponents remembers how opponents play, if GREEDY_OPPONENTS names a file
+// to keep their profiles in.
+var opponents *opponent.Store
+
+// configs holds the bot presets. It is replaced in main if GREEDY_CONFIG
+// names a configuration file.
+var configs, _ = config.NewStore("")
+
+// NewWebsocketService builds the service for a new connection. Each
+// connection gets its own, since a service holds the state of its game.
+// The connection's URL can choose a bot preset, as in "/?bot=hard";
+// otherwise it gets the configuration's default. It can also give the seed
+// of a game to replay, as in "/?seed=42", overriding the preset's. Its
+// logging goes to sessionLog, which identifies the session.
+var NewWebsocketService func(r *http.Request, sessionLog *logging.Logger) WebsocketService = func(r *http.Request, sessionLog *logging.Logger) WebsocketService {
+	c := configs.Config()
+	name := r.URL.Query().Get("bot")
+	preset, ok := c.Preset(name)
+	if !ok {
+		sessionLog.Sub("server").Warn("no such bot preset", "preset", name, "using", c.Default)
+		preset, _ = c.Preset("")
+	}
+	seed := time.Now().UnixNano()
+	if preset.Seed != nil {
+		seed = *preset.Seed
+	}
+	if s := r.URL.Query().Get("seed"); s != "" {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			seed = n
+		} else {
+			sessionLog.Sub("server").Warn("ignoring bad seed", "seed", s)
+		}
+	}
+	svc := pao.NewServiceFor(preset.NewBot(sessionLog.Sub("bot")), seed)
+	svc.Log = sessionLog.Sub("pao")
+	svc.Etiquette = preset.NewEtiquette()
+	svc.Malformed = malformed
+	svc.Levels = levels{sessionLog.Sub("bot")}
+	svc.Opponents = opponents
+	return svc
+}
+
+// levels lets players switch among the configuration's presets with the
+// !level chat command.
+type levels struct {
+	log *logging.Logger
+}
+
+func (l levels) Names() []string {
+	return configs.Config().Names()
+}
+
+func (l levels) NewBot(name string) (pao.Bot, error) {
+	preset, ok := configs.Config().Preset(name)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("no such bot preset %q", name)
+	}
+	return preset.NewBot(l.log), nil
+}
 
 var upgrader = &websocket.Upgrader{
 	ReadBufferSize:  1024,
@@ -30,22 +124,214 @@ func main() {
 	if port == "" {
 		port = "1960"
 	}
+	if err := logging.Default.Configure(os.Getenv("GREEDY_LOG")); err != nil {
+		fmt.Fprintf(os.Stderr, "GREEDY_LOG: %v\n", err)
+		os.Exit(1)
+	}
+	if s := os.Getenv("GREEDY_GRACE"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			log.Error("bad GREEDY_GRACE", "err", err)
+			os.Exit(1)
+		}
+		grace = d
+	}
+	if s := os.Getenv("GREEDY_MALFORMED"); s != "" {
+		policy, err := pao.ParsePolicy(s)
+		if err != nil {
+			log.Error("bad GREEDY_MALFORMED", "err", err)
+			os.Exit(1)
+		}
+		malformed = policy
+	}
+	// GREEDY_WEIGHTS names a weights file, as written by the tuner, whose
+	// piece values replace game.PiecePoints for every bot. Only the piece
+	// values apply: a greedy bot has no use for the rest, and a search bot
+	// that should evaluate with all of them needs a preset that gives them.
+	if path := os.Getenv("GREEDY_WEIGHTS"); path != "" {
+		weights, err := search.LoadWeights(path)
+		if err != nil {
+			log.Error("can't load weights", "err", err)
+			os.Exit(1)
+		}
+		weights.InstallPiecePoints()
+		log.Info("loaded piece values", "path", path, "pieces", fmt.Sprint(weights.Pieces))
+		if weights.Corner != 0 || weights.Edge != 0 || weights.Mobility != 0 {
+			log.Warn("ignoring the corner, edge and mobility weights; give them in a preset to use them",
+				"path", path)
+		}
+	}
+	if path := os.Getenv("GREEDY_OPPONENTS"); path != "" {
+		store, err := opponent.Open(path)
+		if err != nil {
+			log.Error("can't load opponent profiles", "err", err)
+			os.Exit(1)
+		}
+		opponents = store
+	}
+	if path := os.Getenv("GREEDY_CONFIG"); path != "" {
+		store, err := config.NewStore(path)
+		if err != nil {
+			log.Error("can't load configuration", "err", err)
+			os.Exit(1)
+		}
+		store.Log = logging.Default.Sub("config")
+		configs = store
+		log.Info("loaded bot presets", "path", path, "presets", strings.Join(store.Config().Names(), ","))
+		go configs.Watch(context.Background(), 5*time.Second)
+		go reloadOnHangup()
+	}
 	bind := fmt.Sprintf("%v:%v", host, port)
-	fmt.Printf("Listening on %s\n", bind)
+	log.Info("listening", "address", bind)
+	http.Handle("/metrics", metrics.Default)
+	http.Handle("/admin/", admin.Handler(active, logging.Default, os.Getenv("GREEDY_ADMIN_TOKEN")))
+	http.HandleFunc("/healthz", healthz)
+	http.HandleFunc("/readyz", readyz)
 	http.HandleFunc("/", httpHandler)
-	http.ListenAndServe(bind, nil)
+	server := &http.Server{Addr: bind}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Error("server failed", "err", err)
+			os.Exit(1)
+		}
+	}()
+	atomic.StoreInt32(&ready, 1)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	log.Info("shutting down", "signal", (<-stop).String())
+	shutdown(server)
+}
+
+// shutdown stops taking new games, closes sessions that are between games,
+// waits up to grace for the games in progress to finish, and resigns the
+// rest. Health checks and the admin
+// API keep working until the last session has ended.
+func shutdown(server *http.Server) {
+	start := time.Now()
+	atomic.StoreInt32(&ready, 0)
+	waiting := active.Len()
+	log.Info("draining sessions", "sessions", waiting, "grace", grace.String())
+	closed, resigned, terminated := drain(grace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	log.Info("shut down", "sessions", atomic.LoadInt64(&sessions),
+		"finished", waiting-closed-resigned, "closed", closed, "resigned", resigned,
+		"terminated", terminated, "took", time.Since(start).Round(time.Millisecond).String())
+}
+
+// resignWait is how long drain waits for sessions to end after resigning
+// them, before it terminates them.
+var resignWait = 10 * time.Second
+
+// drain ends every session. Sessions between games are closed at once, and
+// the others as soon as their games end. Games still going after grace are
+// resigned, and sessions that don't end even then are terminated. It
+// counts the sessions it closed and resigned, and of those, how many it
+// had to terminate.
+func drain(grace time.Duration) (closed, resigned, terminated int) {
+	told := map[int64]bool{}
+	closeIdle := func() {
+		active.Each(func(id int64, s admin.Session) {
+			if told[id] || s.Status().Playing {
+				return
+			}
+			log.Info("closing idle session", "session", id)
+			told[id] = true
+			s.(WebsocketService).Resign()
+			closed++
+		})
+	}
+	if awaitSessions(grace, closeIdle) {
+		return
+	}
+	active.Each(func(id int64, s admin.Session) {
+		if told[id] {
+			return
+		}
+		log.Info("resigning session", "session", id)
+		told[id] = true
+		s.(WebsocketService).Resign()
+		resigned++
+	})
+	if !awaitSessions(resignWait, nil) {
+		active.Each(func(id int64, s admin.Session) {
+			log.Warn("terminating session", "session", id)
+			s.Terminate()
+			terminated++
+		})
+		awaitSessions(time.Second, nil)
+	}
+	return
+}
+
+// awaitSessions waits up to timeout for every session to end, and says
+// whether they did. While it waits, it calls poll, if it isn't nil, every
+// time it looks.
+func awaitSessions(timeout time.Duration, poll func()) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if poll != nil {
+			poll()
+		}
+		if active.Len() == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
 }
 
 func httpHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("%v - Got a new customer!\n", time.Now())
-	fmt.Printf("%v - Request: %v\n", time.Now(), r)
+	if atomic.LoadInt32(&ready) == 0 {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	id := atomic.AddInt64(&sessions, 1)
+	sessionLog := logging.Default.With("session", id)
+	log := sessionLog.Sub("server")
+	log.Info("new connection", "remote", r.RemoteAddr, "url", r.URL.String())
 
 	if conn, err := upgrader.Upgrade(w, r, nil); err != nil {
-		fmt.Printf("%v - Websocket build error: %v\n", time.Now(), err.Error())
+		log.Warn("websocket upgrade failed", "err", err)
 	} else {
-		fmt.Printf("%v - Spinning up a websocket goroutine\n", time.Now())
-		go PaoService.Run(conn)
+		svc := NewWebsocketService(r, sessionLog)
+		active.Add(id, r.RemoteAddr, svc)
+		go func() {
+			defer active.Remove(id)
+			svc.Run(conn)
+		}()
 	}
+}
 
-	fmt.Printf("%v - Exiting HTTP Handler\n", time.Now())
+// healthz answers as long as the server is running at all.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz answers with success only while the server is taking new games.
+func readyz(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&ready) == 0 {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+// reloadOnHangup rereads the configuration file whenever the server gets a
+// SIGHUP, for those who would rather not wait for it to be noticed.
+func reloadOnHangup() {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for range hangups {
+		if err := configs.Reload(); err != nil {
+			log.Warn("keeping the old configuration", "err", err)
+		} else {
+			log.Info("reloaded configuration")
+		}
+	}
 }
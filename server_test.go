@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/perlmonger42/greedy-bot/admin"
+	"github.com/perlmonger42/greedy-bot/pao"
+)
+
+// fakeSession stands in for a session, ending when it's resigned or
+// terminated. A stubborn one ignores being resigned.
+type fakeSession struct {
+	id       int64
+	stubborn bool
+
+	mu                   sync.Mutex
+	playing              bool
+	resigned, terminated time.Time
+}
+
+func (f *fakeSession) Run(*websocket.Conn) {}
+
+func (f *fakeSession) Status() pao.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return pao.Status{Playing: f.playing}
+}
+
+func (f *fakeSession) Resign() {
+	f.mu.Lock()
+	f.resigned = time.Now()
+	f.mu.Unlock()
+	if !f.stubborn {
+		active.Remove(f.id)
+	}
+}
+
+func (f *fakeSession) Terminate() {
+	f.mu.Lock()
+	f.terminated = time.Now()
+	f.mu.Unlock()
+	active.Remove(f.id)
+}
+
+// endGame finishes the session's game, leaving it idle.
+func (f *fakeSession) endGame() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.playing = false
+}
+
+func (f *fakeSession) times() (resigned, terminated time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.resigned, f.terminated
+}
+
+func TestDrain(t *testing.T) {
+	saved, savedWait := active, resignWait
+	defer func() { active, resignWait = saved, savedWait }()
+	active, resignWait = admin.NewRegistry(), 300*time.Millisecond
+
+	idle := &fakeSession{id: 1}
+	finishing := &fakeSession{id: 2, playing: true}
+	slow := &fakeSession{id: 3, playing: true}
+	stuck := &fakeSession{id: 4, playing: true, stubborn: true}
+	for _, f := range []*fakeSession{idle, finishing, slow, stuck} {
+		active.Add(f.id, "test", f)
+	}
+
+	const grace = time.Second
+	start := time.Now()
+	time.AfterFunc(grace/4, finishing.endGame)
+	closed, resigned, terminated := drain(grace)
+	if closed != 2 || resigned != 2 || terminated != 1 {
+		t.Errorf("closed %d, resigned %d and terminated %d sessions, want 2, 2 and 1",
+			closed, resigned, terminated)
+	}
+	if n := active.Len(); n != 0 {
+		t.Errorf("%d sessions left", n)
+	}
+
+	if r, _ := idle.times(); r.Sub(start) > grace/4 {
+		t.Errorf("closed the idle session after %v, want at once", r.Sub(start))
+	}
+	if r, _ := finishing.times(); r.Sub(start) < grace/4 || r.Sub(start) > grace/2 {
+		t.Errorf("closed the session whose game finished after %v, want as soon as it did", r.Sub(start))
+	}
+	if r, _ := slow.times(); r.Sub(start) < grace {
+		t.Errorf("resigned a game in progress after %v, before the grace period was up", r.Sub(start))
+	}
+	for _, f := range []*fakeSession{idle, finishing, slow} {
+		if _, term := f.times(); !term.IsZero() {
+			t.Errorf("terminated session %d, which ended when it was resigned", f.id)
+		}
+	}
+	if r, term := stuck.times(); r.IsZero() || term.Sub(r) < resignWait {
+		t.Errorf("resigned the stuck session at %v and terminated it at %v, want termination %v after resigning",
+			r, term, resignWait)
+	}
+}
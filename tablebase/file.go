@@ -0,0 +1,133 @@
+package tablebase
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// A table file is a short header followed by the table's entries, packed
+// as little-endian uint16s and gzipped (most entries are draws or
+// illegal positions, so they compress well):
+//
+//	"BQTB"          magic
+//	version         1 byte
+//	n               1 byte, the number of pieces
+//	pieces          n bytes, each a piece's Pao descriptor
+//	entries         gzip stream of 2<<(5n) uint16s
+const (
+	fileMagic   = "BQTB"
+	fileVersion = 1
+	fileSuffix  = ".tb"
+)
+
+var ErrBadFile = errors.New("not a tablebase file")
+
+// FileName is the name of the file holding the table for m.
+func FileName(m Material) string {
+	return m.Name() + fileSuffix
+}
+
+// WriteTo writes the table in the on-disk format. It returns the number of
+// bytes written, so that a Table is an io.WriterTo.
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	header := []byte(fileMagic)
+	header = append(header, fileVersion, byte(len(t.Material)))
+	for _, p := range t.Material {
+		header = append(header, p.Descriptor()[0])
+	}
+	if _, err := cw.Write(header); err != nil {
+		return cw.n, err
+	}
+	zw := gzip.NewWriter(cw)
+	bw := bufio.NewWriter(zw)
+	if err := binary.Write(bw, binary.LittleEndian, t.entries); err != nil {
+		return cw.n, err
+	}
+	if err := bw.Flush(); err != nil {
+		return cw.n, err
+	}
+	err := zw.Close()
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// ReadTable reads a table written by WriteTo.
+func ReadTable(r io.Reader) (*Table, error) {
+	header := make([]byte, len(fileMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(fileMagic)]) != fileMagic {
+		return nil, ErrBadFile
+	}
+	if v := header[len(fileMagic)]; v != fileVersion {
+		return nil, fmt.Errorf("%w: unknown version %d", ErrBadFile, v)
+	}
+	n := int(header[len(fileMagic)+1])
+	if n < 1 || n > MaxPieces {
+		return nil, fmt.Errorf("%w: %d pieces", ErrBadFile, n)
+	}
+	descriptors := make([]byte, n)
+	if _, err := io.ReadFull(r, descriptors); err != nil {
+		return nil, err
+	}
+	pieces := Material{}
+	for _, d := range descriptors {
+		p, ok := pieceByDescriptor[string(d)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown piece %q", ErrBadFile, d)
+		}
+		pieces = append(pieces, p)
+	}
+	t := &Table{Material: NewMaterial(pieces...)}
+	t.entries = make([]uint16, t.Material.size())
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := binary.Read(bufio.NewReader(zr), binary.LittleEndian, t.entries); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Save writes the table into dir, under its FileName.
+func (t *Table) Save(dir string) error {
+	f, err := os.Create(filepath.Join(dir, FileName(t.Material)))
+	if err != nil {
+		return err
+	}
+	if _, err := t.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads the table for m from dir.
+func Load(dir string, m Material) (*Table, error) {
+	f, err := os.Open(filepath.Join(dir, FileName(m)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTable(bufio.NewReader(f))
+}
@@ -0,0 +1,211 @@
+package tablebase
+
+import (
+	"sort"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// Generator builds tables by retrograde analysis, along with the smaller
+// tables they depend on (since every capture leads to a smaller material).
+type Generator struct {
+	tables map[string]*Table
+}
+
+func NewGenerator() *Generator {
+	return &Generator{tables: map[string]*Table{}}
+}
+
+// Add makes an already-built table available to the generator, so that it
+// needn't be generated again.
+func (g *Generator) Add(t *Table) {
+	g.tables[t.Material.Name()] = t
+}
+
+// Tables returns every table built or added so far, ordered by name.
+func (g *Generator) Tables() []*Table {
+	tables := []*Table{}
+	for _, t := range g.tables {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Material.Name() < tables[j].Material.Name()
+	})
+	return tables
+}
+
+// Generate returns the table for m, building it (and any smaller tables it
+// needs) if necessary.
+func (g *Generator) Generate(m Material) *Table {
+	if t, ok := g.tables[m.Name()]; ok {
+		return t
+	}
+	subtables := make([]*Table, len(m))
+	for i := range m {
+		if len(m) > 1 {
+			subtables[i] = g.Generate(m.without(i))
+		}
+	}
+	t := solve(m, subtables)
+	g.tables[m.Name()] = t
+	return t
+}
+
+// solve runs the retrograde analysis for material m. subtables[i] is the
+// table for m without its i'th piece.
+//
+// Every position starts out unresolved, with a count of its moves that
+// aren't yet known to lose. Positions with no moves are lost; positions
+// with a capture into a lost position are won. From there, the analysis
+// works backward one ply at a time: a predecessor of a lost position is
+// won, and a predecessor whose moves all turn out to lead to won positions
+// is lost. Whatever is never resolved is a draw. Only sliding moves stay
+// within a table, and a slide is always reversible, so the predecessors of
+// a position are found by sliding the last mover's pieces backward.
+func solve(m Material, subtables []*Table) *Table {
+	t := &Table{Material: m, entries: make([]uint16, m.size())}
+	resolved := make([]bool, m.size())
+	pending := make([]uint8, m.size())
+	queue := []int{}
+	later := []int{} // resolved at distance 1, queued after distance 0
+
+	squares := make([]int, len(m))
+	for idx := range t.entries {
+		black, ok := m.decode(idx, squares)
+		if !ok {
+			continue
+		}
+		us, them := &game.RedTeam, &game.BlackTeam
+		if black {
+			us, them = them, us
+		}
+		if !m.has(them) {
+			// The other side has nothing left, so the game is already over;
+			// no move can lead here, so these positions are never queued.
+			t.entries[idx], resolved[idx] = pack(Result{WDL: Win}), true
+			continue
+		}
+		moves := move.LegalMoves(us, them, m.board(squares))
+		if len(moves) == 0 {
+			t.entries[idx], resolved[idx] = pack(Result{WDL: Loss}), true
+			queue = append(queue, idx)
+			continue
+		}
+		wins, count := false, 0
+		for _, mv := range moves {
+			if mv.Action() != move.Take {
+				count++
+				continue
+			}
+			switch lookupCapture(squares, black, mv, subtables).WDL {
+			case Loss:
+				wins = true
+			case Draw:
+				count++
+			}
+		}
+		if wins {
+			t.entries[idx], resolved[idx] = pack(Result{WDL: Win, Distance: 1}), true
+			later = append(later, idx)
+		} else if count == 0 {
+			t.entries[idx], resolved[idx] = pack(Result{WDL: Loss, Distance: 1}), true
+			later = append(later, idx)
+		} else {
+			pending[idx] = uint8(count)
+		}
+	}
+	queue = append(queue, later...)
+
+	pred := make([]int, len(m))
+	for len(queue) > 0 {
+		idx := queue[0]
+		queue = queue[1:]
+		r := t.at(idx)
+		black, _ := m.decode(idx, squares)
+		// The previous move was made by the other side.
+		mover := &game.RedTeam
+		if !black {
+			mover = &game.BlackTeam
+		}
+		m.eachUnslide(squares, mover, func(moved []int) {
+			p := m.index(moved, !black)
+			if resolved[p] {
+				return
+			}
+			if r.WDL == Loss {
+				t.entries[p], resolved[p] = pack(Result{WDL: Win, Distance: r.Distance + 1}), true
+				queue = append(queue, p)
+			} else if pending[p]--; pending[p] == 0 {
+				t.entries[p], resolved[p] = pack(Result{WDL: Loss, Distance: r.Distance + 1}), true
+				queue = append(queue, p)
+			}
+		}, pred)
+	}
+	return t
+}
+
+// has tells whether any of m's pieces belong to team.
+func (m Material) has(team *game.Team) bool {
+	for _, p := range m {
+		if team.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// eachUnslide calls visit with the squares of every position from which
+// one of mover's pieces could have slid to give the position on squares.
+// The slice passed to visit is reused between calls.
+func (m Material) eachUnslide(squares []int, mover *game.Team, visit func([]int), scratch []int) {
+	occupied := uint32(0)
+	for _, sq := range squares {
+		occupied |= 1 << uint(sq)
+	}
+	for i, sq := range squares {
+		if !mover.Contains(m[i]) {
+			continue
+		}
+		r, c := sq/8, sq%8
+		for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			r2, c2 := r+d[0], c+d[1]
+			if r2 < 0 || r2 >= 4 || c2 < 0 || c2 >= 8 {
+				continue
+			}
+			from := r2*8 + c2
+			if occupied&(1<<uint(from)) != 0 {
+				continue
+			}
+			copy(scratch, squares)
+			scratch[i] = from
+			visit(scratch)
+		}
+	}
+}
+
+// lookupCapture finds the result, for the side then to move, of the
+// position after the capture mv. squares and black describe the position
+// before the capture.
+func lookupCapture(squares []int, black bool, mv move.T, subtables []*Table) Result {
+	from := mv.From().Row()*8 + mv.From().Col()
+	to := mv.To().Row()*8 + mv.To().Col()
+	victim := -1
+	for i, sq := range squares {
+		if sq == to {
+			victim = i
+		}
+	}
+	rest := make([]int, 0, len(squares)-1)
+	for i, sq := range squares {
+		if i == victim {
+			continue
+		}
+		if sq == from {
+			sq = to
+		}
+		rest = append(rest, sq)
+	}
+	sub := subtables[victim]
+	return sub.at(sub.Material.index(rest, !black))
+}
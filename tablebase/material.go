@@ -0,0 +1,128 @@
+// Package tablebase solves Banqi endings by retrograde analysis. Once every
+// piece is face up, the game has no more chance in it, so a position with
+// few enough pieces can be solved outright: won, lost, or drawn, and how
+// many plies remain until the next capture (or the end of the game).
+package tablebase
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/perlmonger42/greedy-bot/game"
+)
+
+// MaxPieces is the most pieces a table may have. Tables grow by a factor
+// of 32 with every piece, and four pieces is already two million entries.
+const MaxPieces = 4
+
+// Material is the set of pieces on the board, in ascending Piece order.
+type Material []game.Piece
+
+// NewMaterial sorts the given pieces into a Material.
+func NewMaterial(pieces ...game.Piece) Material {
+	m := append(Material{}, pieces...)
+	sort.Slice(m, func(i, j int) bool { return m[i] < m[j] })
+	return m
+}
+
+// ParseMaterial reads a material name like "kg_v_c": the red pieces, then
+// the black pieces, each written as lowercase Pao descriptors.
+func ParseMaterial(name string) (Material, error) {
+	sides := strings.Split(name, "_v_")
+	if len(sides) != 2 {
+		return nil, fmt.Errorf("material %q should look like \"kg_v_c\"", name)
+	}
+	pieces := []game.Piece{}
+	for i, side := range sides {
+		for _, r := range side {
+			str := strings.ToLower(string(r))
+			if i == 1 {
+				str = strings.ToUpper(str)
+			}
+			p, ok := pieceByDescriptor[str]
+			if !ok {
+				return nil, fmt.Errorf("material %q: unknown piece %q", name, r)
+			}
+			pieces = append(pieces, p)
+		}
+	}
+	if len(pieces) == 0 || len(pieces) > MaxPieces {
+		return nil, fmt.Errorf("material %q: tables hold 1 to %d pieces", name, MaxPieces)
+	}
+	return NewMaterial(pieces...), nil
+}
+
+var pieceByDescriptor = map[string]game.Piece{}
+
+func init() {
+	for _, team := range game.Teams {
+		for _, p := range team.QPHCEGK {
+			pieceByDescriptor[p.Descriptor()] = p
+		}
+	}
+}
+
+// Name returns the material's name, as understood by ParseMaterial. Both
+// sides are written in lowercase, so that names are safe to use as file
+// names on case-insensitive file systems.
+func (m Material) Name() string {
+	red, black := "", ""
+	for _, p := range m {
+		if game.RedTeam.Contains(p) {
+			red += p.Descriptor()
+		} else {
+			black += strings.ToLower(p.Descriptor())
+		}
+	}
+	return red + "_v_" + black
+}
+
+// without returns the material left after the i'th piece is captured.
+func (m Material) without(i int) Material {
+	rest := append(Material{}, m[:i]...)
+	return append(rest, m[i+1:]...)
+}
+
+// size is the number of entries in a table for m: a square for each piece,
+// and which side is to move.
+func (m Material) size() int {
+	return 2 << (5 * uint(len(m)))
+}
+
+// index numbers a position: squares[i] holds m[i], and black tells whether
+// it is Black's turn.
+func (m Material) index(squares []int, black bool) int {
+	idx := 0
+	if black {
+		idx = 1 << (5 * uint(len(m)))
+	}
+	for i, sq := range squares {
+		idx |= sq << (5 * uint(i))
+	}
+	return idx
+}
+
+// decode is the inverse of index. It reports false for indexes that put
+// two pieces on the same square.
+func (m Material) decode(idx int, squares []int) (black, ok bool) {
+	used := uint32(0)
+	for i := range m {
+		sq := (idx >> (5 * uint(i))) & 31
+		if used&(1<<uint(sq)) != 0 {
+			return false, false
+		}
+		used |= 1 << uint(sq)
+		squares[i] = sq
+	}
+	return idx>>(5*uint(len(m))) != 0, true
+}
+
+// board lays out the pieces of m on the given squares.
+func (m Material) board(squares []int) game.Board {
+	board := game.Board{}
+	for i, sq := range squares {
+		board[sq/8][sq%8] = m[i]
+	}
+	return board
+}
@@ -0,0 +1,129 @@
+package tablebase
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+// Set is a collection of tables that bots can consult. Tables are read
+// from the set's directory the first time they are needed.
+type Set struct {
+	dir    string
+	mu     sync.Mutex
+	tables map[string]*Table // nil entries mark tables known to be missing
+}
+
+// Open returns a Set that loads its tables from dir.
+func Open(dir string) *Set {
+	return &Set{dir: dir, tables: map[string]*Table{}}
+}
+
+// NewSet returns a Set holding just the given tables.
+func NewSet(tables ...*Table) *Set {
+	s := &Set{tables: map[string]*Table{}}
+	for _, t := range tables {
+		s.tables[t.Material.Name()] = t
+	}
+	return s
+}
+
+func (s *Set) table(m Material) *Table {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := m.Name()
+	t, ok := s.tables[name]
+	if !ok && s.dir != "" {
+		t, _ = Load(s.dir, m) // a missing table just means we can't help
+		s.tables[name] = t
+	}
+	return t
+}
+
+// Probe looks gs up in the tables. It only succeeds if every piece is face
+// up, and the set has a table for the pieces on the board.
+func (s *Set) Probe(gs *game.State) (Result, bool) {
+	if gs.Us == nil || len(gs.Down) != 0 {
+		return Result{}, false
+	}
+	type placed struct {
+		piece  game.Piece
+		square int
+	}
+	pieces := []placed{}
+	for r, row := range gs.Board {
+		for c, p := range row {
+			if p == game.FaceDown {
+				return Result{}, false
+			} else if p != game.None {
+				pieces = append(pieces, placed{p, r*8 + c})
+			}
+		}
+	}
+	if len(pieces) == 0 || len(pieces) > MaxPieces {
+		return Result{}, false
+	}
+	sort.SliceStable(pieces, func(i, j int) bool { return pieces[i].piece < pieces[j].piece })
+	m, squares := Material{}, []int{}
+	for _, p := range pieces {
+		m, squares = append(m, p.piece), append(squares, p.square)
+	}
+	t := s.table(m)
+	if t == nil {
+		return Result{}, false
+	}
+	return t.at(m.index(squares, gs.Us.K == game.BlackKing)), true
+}
+
+// BestMove finds the move that plays the position in gs perfectly: the
+// quickest win, a move that holds a draw, or the longest resistance to a
+// loss. The result is the value of gs itself.
+func (s *Set) BestMove(gs *game.State) (move.T, Result, bool) {
+	here, ok := s.Probe(gs)
+	if !ok {
+		return move.NewQuit(), Result{}, false
+	}
+	best, bestRank := move.NewQuit(), 0
+	for _, m := range move.LegalMoves(gs.Us, gs.Them, gs.Board) {
+		child := m.Apply(gs)
+		r, ok := s.Probe(&child)
+		if !ok && pieceLeft(&child) == game.None {
+			r, ok = Result{WDL: Loss}, true // that capture ended the game
+		}
+		if !ok {
+			return move.NewQuit(), Result{}, false // a table we need is missing
+		}
+		if rank := rankMove(r); best.Action() == move.Quit || rank > bestRank {
+			best, bestRank = m, rank
+		}
+	}
+	return best, here, best.Action() != move.Quit
+}
+
+// rankMove orders moves by the result they leave the opponent with: we
+// prefer the opponent lost (sooner is better), then drawn, then won
+// (later is better).
+func rankMove(opponent Result) int {
+	switch opponent.WDL {
+	case Loss:
+		return 2*(distanceMax+1) - opponent.Distance
+	case Draw:
+		return distanceMax + 1
+	}
+	return opponent.Distance
+}
+
+// pieceLeft returns any piece on the board belonging to the side to move,
+// or None if it has none.
+func pieceLeft(gs *game.State) game.Piece {
+	for _, row := range gs.Board {
+		for _, p := range row {
+			if gs.Us.Contains(p) {
+				return p
+			}
+		}
+	}
+	return game.None
+}
@@ -0,0 +1,72 @@
+package tablebase
+
+// WDL is the game-theoretic value of a position for the side to move.
+type WDL int8
+
+const (
+	Loss WDL = -1
+	Draw WDL = 0
+	Win  WDL = 1
+)
+
+func (w WDL) String() string {
+	switch w {
+	case Loss:
+		return "loss"
+	case Win:
+		return "win"
+	}
+	return "draw"
+}
+
+// Result is what a table knows about a position.
+type Result struct {
+	WDL WDL
+	// Distance is the number of plies until the next capture or the end of
+	// the game, with the winner hurrying and the loser stalling. It is
+	// zero for drawn positions, and for lost positions with no moves.
+	Distance int
+}
+
+// Table holds the solution of every position for one material.
+type Table struct {
+	Material Material
+	entries  []uint16
+}
+
+// Entries are packed into 16 bits: the top two hold the WDL, the rest hold
+// the distance.
+const (
+	winBit      = 0x4000
+	lossBit     = 0x8000
+	distanceMax = 0x3fff
+)
+
+func pack(r Result) uint16 {
+	d := r.Distance
+	if d > distanceMax {
+		d = distanceMax
+	}
+	switch r.WDL {
+	case Win:
+		return winBit | uint16(d)
+	case Loss:
+		return lossBit | uint16(d)
+	}
+	return 0
+}
+
+func unpack(e uint16) Result {
+	d := int(e & distanceMax)
+	switch {
+	case e&winBit != 0:
+		return Result{WDL: Win, Distance: d}
+	case e&lossBit != 0:
+		return Result{WDL: Loss, Distance: d}
+	}
+	return Result{WDL: Draw}
+}
+
+func (t *Table) at(idx int) Result {
+	return unpack(t.entries[idx])
+}
@@ -0,0 +1,163 @@
+package tablebase
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/game"
+	"github.com/perlmonger42/greedy-bot/move"
+)
+
+func generate(t *testing.T, name string) (*Generator, *Table) {
+	m, err := ParseMaterial(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGenerator()
+	return g, g.Generate(m)
+}
+
+func TestParseMaterial(t *testing.T) {
+	m, err := ParseMaterial("kg_v_c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewMaterial(game.RedKing, game.RedGuard, game.BlackCart)
+	if len(m) != len(want) || m[0] != want[0] || m[1] != want[1] || m[2] != want[2] {
+		t.Errorf("expected %v; got %v", want, m)
+	}
+	if m.Name() != "gk_v_c" {
+		t.Errorf("expected the canonical name gk_v_c; got %s", m.Name())
+	}
+	for _, bad := range []string{"kgc", "kx_v_c", "kgeh_v_c"} {
+		if _, err := ParseMaterial(bad); err == nil {
+			t.Errorf("%q should not parse", bad)
+		}
+	}
+}
+
+func TestCaptureWins(t *testing.T) {
+	g, _ := generate(t, "g_v_h")
+	gs := game.NewState("Red", [][]string{
+		{"g", "H", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+		{".", ".", ".", ".", ".", ".", ".", "."},
+	}, []string{
+		"q", "q", "p", "p", "p", "p", "p", "h", "h", "c", "c", "e", "e", "g", "k",
+		"Q", "Q", "P", "P", "P", "P", "P", "H", "C", "C", "E", "E", "G", "G", "K",
+	})
+	m, r, ok := NewSet(g.Tables()...).BestMove(&gs)
+	if !ok {
+		t.Fatalf("the position should be in the tables")
+	}
+	if r != (Result{WDL: Win, Distance: 1}) {
+		t.Errorf("expected a win in 1; got %+v", r)
+	}
+	if have, want := m.String(), "RedGuard at A1 takes BlackHorse at B1"; have != want {
+		t.Errorf("expected %s; got %s", want, have)
+	}
+}
+
+// TestTableIsConsistent checks every position of a table against its
+// successors: a win must have a move to a loss one ply shorter, and a loss
+// must have every move leading to a win no longer than one ply shorter.
+func TestTableIsConsistent(t *testing.T) {
+	g, table := generate(t, "gp_v_h")
+	set := NewSet(g.Tables()...)
+	m := table.Material
+	squares := make([]int, len(m))
+	wins, losses, draws := 0, 0, 0
+	for idx := range table.entries {
+		black, ok := m.decode(idx, squares)
+		if !ok {
+			continue
+		}
+		gs := game.State{Board: m.board(squares), Us: &game.RedTeam, Them: &game.BlackTeam}
+		if black {
+			gs.Us, gs.Them = gs.Them, gs.Us
+		}
+		if !m.has(gs.Them) {
+			continue
+		}
+		r := table.at(idx)
+		best := -1 // shortest win or longest loss among the children, for the opponent
+		switch r.WDL {
+		case Win:
+			wins++
+		case Loss:
+			losses++
+		case Draw:
+			draws++
+		}
+		for _, mv := range move.LegalMoves(gs.Us, gs.Them, gs.Board) {
+			child := mv.Apply(&gs)
+			cr, ok := set.Probe(&child)
+			if !ok {
+				t.Fatalf("missing table for %s", mv.String())
+			}
+			if mv.Action() == move.Take {
+				cr.Distance = 0 // captures convert, whatever follows
+			}
+			switch {
+			case r.WDL == Win && cr.WDL == Loss:
+				if best < 0 || cr.Distance < best {
+					best = cr.Distance
+				}
+			case r.WDL == Loss && cr.WDL != Win:
+				t.Fatalf("position %d is lost, but %s doesn't lose", idx, mv.String())
+			case r.WDL == Loss && cr.Distance > best:
+				best = cr.Distance
+			case r.WDL == Draw && cr.WDL == Loss:
+				t.Fatalf("position %d is drawn, but %s wins", idx, mv.String())
+			}
+		}
+		if r.WDL != Draw && r.Distance > 0 && best+1 != r.Distance {
+			t.Fatalf("position %d: %+v, but the best child is %d", idx, r, best)
+		}
+	}
+	if wins == 0 || losses == 0 {
+		t.Errorf("expected some won and lost positions; got %d/%d/%d", wins, losses, draws)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	_, table := generate(t, "g_v_h")
+	var buf bytes.Buffer
+	n, err := table.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo wrote %d bytes, but says it wrote %d", buf.Len(), n)
+	}
+	if buf.Len() >= 2*len(table.entries) {
+		t.Errorf("expected the file to be compressed; it takes %d bytes", buf.Len())
+	}
+
+	dir, err := ioutil.TempDir("", "tablebase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := table.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(dir, table.Material)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Material.Name() != table.Material.Name() {
+		t.Errorf("expected material %s; got %s", table.Material.Name(), loaded.Material.Name())
+	}
+	for i := range table.entries {
+		if loaded.entries[i] != table.entries[i] {
+			t.Fatalf("entry %d differs after loading", i)
+		}
+	}
+	if _, err := ReadTable(bytes.NewReader([]byte("nonsense"))); err == nil {
+		t.Errorf("expected an error reading a bad file")
+	}
+}
@@ -0,0 +1,135 @@
+// Package tune fits evaluation weights to game outcomes, Texel-style.
+//
+// Each position's evaluation is squashed into a predicted result by a
+// logistic curve, 1/(1+exp(-K*eval)), and the tuner minimizes the mean
+// squared difference between the predictions and the actual results. K is
+// chosen first, to fit the starting weights as well as possible; then the
+// weights are adjusted one at a time, keeping any change that lowers the
+// error, with smaller and smaller steps until none helps.
+package tune
+
+import (
+	"math"
+
+	"github.com/perlmonger42/greedy-bot/dataset"
+	"github.com/perlmonger42/greedy-bot/search"
+)
+
+// Sample is a position reduced to what the tuner needs.
+type Sample struct {
+	Features []float64
+	Result   float64
+}
+
+// NewSamples computes the features of every position.
+func NewSamples(positions []dataset.Position) []Sample {
+	samples := make([]Sample, len(positions))
+	for i := range positions {
+		gs := positions[i].State()
+		samples[i] = Sample{Features: search.Features(&gs), Result: positions[i].Result}
+	}
+	return samples
+}
+
+// Tuner holds the state of a tuning run.
+type Tuner struct {
+	Samples []Sample
+	K       float64 // the logistic curve's steepness
+	// Fixed lists the indexes of weights that must not change. It is
+	// usual to fix one piece's value, to pin down the evaluation's scale.
+	Fixed map[int]bool
+	// Progress, if set, is called after every pass with the current error.
+	Progress func(pass int, err float64, w search.Weights)
+}
+
+func NewTuner(samples []Sample) *Tuner {
+	return &Tuner{Samples: samples, K: 1, Fixed: map[int]bool{}}
+}
+
+// Error is the mean squared error of the predictions made with weights v.
+func (t *Tuner) Error(v []float64) float64 {
+	if len(t.Samples) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, s := range t.Samples {
+		eval := 0.0
+		for i, f := range s.Features {
+			eval += f * v[i]
+		}
+		d := s.Result - 1/(1+math.Exp(-t.K*eval))
+		sum += d * d
+	}
+	return sum / float64(len(t.Samples))
+}
+
+// FitK chooses the K that best fits the weights v, by golden-section
+// search over a generous range of scales.
+func (t *Tuner) FitK(v []float64) float64 {
+	errAt := func(logK float64) float64 {
+		t.K = math.Exp(logK)
+		return t.Error(v)
+	}
+	lo, hi := math.Log(1e-6), math.Log(10.0)
+	phi := (math.Sqrt(5) - 1) / 2
+	a, b := hi-phi*(hi-lo), lo+phi*(hi-lo)
+	fa, fb := errAt(a), errAt(b)
+	for i := 0; i < 60; i++ {
+		if fa < fb {
+			hi, b, fb = b, a, fa
+			a = hi - phi*(hi-lo)
+			fa = errAt(a)
+		} else {
+			lo, a, fa = a, b, fb
+			b = lo + phi*(hi-lo)
+			fb = errAt(b)
+		}
+	}
+	t.K = math.Exp((lo + hi) / 2)
+	return t.K
+}
+
+// Tune improves on the starting weights, and returns the best weights
+// found along with their error. It stops after maxPasses passes over the
+// weights, or sooner if no step of size 1 improves anything.
+func (t *Tuner) Tune(start search.Weights, maxPasses int) (search.Weights, float64) {
+	v := start.Vector()
+	best := t.Error(v)
+	steps := make([]float64, len(v))
+	for i, w := range v {
+		steps[i] = math.Max(1, math.Abs(w)/8)
+	}
+	for pass := 1; pass <= maxPasses; pass++ {
+		improved := false
+		for i := range v {
+			if t.Fixed[i] {
+				continue
+			}
+			for _, dir := range []float64{1, -1} {
+				old := v[i]
+				v[i] = old + dir*steps[i]
+				if e := t.Error(v); e < best {
+					best, improved = e, true
+					break
+				}
+				v[i] = old
+			}
+		}
+		if t.Progress != nil {
+			t.Progress(pass, best, search.WeightsFromVector(v))
+		}
+		if !improved {
+			shrunk := false
+			for i := range steps {
+				if steps[i] > 1 {
+					steps[i] = math.Max(1, math.Floor(steps[i]/2))
+					shrunk = true
+				}
+			}
+			if !shrunk {
+				break
+			}
+		}
+	}
+	return search.WeightsFromVector(v), best
+}
@@ -0,0 +1,43 @@
+package tune
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/perlmonger42/greedy-bot/search"
+)
+
+// TestTuneLearnsMobility builds samples in which the side with more moves
+// usually wins, whatever the materiel, and expects the tuner to notice.
+func TestTuneLearnsMobility(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	samples := []Sample{}
+	for i := 0; i < 2000; i++ {
+		f := make([]float64, search.NumFeatures)
+		f[1] = float64(r.Intn(5) - 2) // pawns
+		mobility := float64(r.Intn(21) - 10)
+		f[search.NumFeatures-1] = mobility
+		result := 0.0
+		if r.Float64() < 1/(1+math.Exp(-0.3*mobility)) {
+			result = 1
+		}
+		samples = append(samples, Sample{Features: f, Result: result})
+	}
+
+	tuner := NewTuner(samples)
+	tuner.Fixed[0] = true
+	start := search.DefaultWeights()
+	tuner.FitK(start.Vector())
+	before := tuner.Error(start.Vector())
+	w, after := tuner.Tune(start, 100)
+	if after >= before {
+		t.Errorf("tuning should reduce the error below %f; got %f", before, after)
+	}
+	if w.Mobility <= 0 {
+		t.Errorf("mobility should have a positive weight; got %+v", w)
+	}
+	if w.Pieces["cannon"] != start.Pieces["cannon"] {
+		t.Errorf("the cannon's value was fixed, but changed to %d", w.Pieces["cannon"])
+	}
+}